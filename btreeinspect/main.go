@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/xhebox/sbutils/lib/btreedb5"
+)
+
+func ptr(p uint) string {
+	if p == uint(^uint32(0)) {
+		return "none"
+	}
+
+	return fmt.Sprint(p)
+}
+
+func printHeader(h *btreedb5.BTreeDB5) {
+	hdr := h.Header()
+
+	fmt.Printf("identifier: %q\n", hdr.Identifier)
+	fmt.Printf("block size: %d\n", hdr.BlockSize)
+	fmt.Printf("key size: %d\n", hdr.KeySize)
+	fmt.Printf("use alt root: %v\n", hdr.UseAltRoot)
+
+	for k, r := range hdr.Roots {
+		name := "root"
+		if k == 1 {
+			name = "alt root"
+		}
+
+		fmt.Printf("%s:\n", name)
+		fmt.Printf("\tfree index: %s\n", ptr(r.FreeIndex))
+		fmt.Printf("\tdevice size: %d\n", r.DeviceSize)
+		fmt.Printf("\troot block: %s\n", ptr(r.RootBlock))
+		fmt.Printf("\troot is leaf: %v\n", r.RootIsLeaf)
+	}
+}
+
+func printBlock(h *btreedb5.BTreeDB5, blk uint) {
+	r, e := h.Block(blk)
+	if e != nil {
+		log.Fatalf("%+v\n", e)
+	}
+
+	switch r.Type {
+	case btreedb5.IndexNode:
+		fmt.Printf("block %d: index\n", r.Ptr)
+		fmt.Printf("height: %d\n", r.Height)
+		fmt.Printf("keys: %d\n", len(r.Keys))
+		fmt.Printf("\t\t-> %s\n", ptr(r.Ptrs[0]))
+		for k := range r.Keys {
+			fmt.Printf("\t%s\t-> %s\n", hex.EncodeToString(r.Keys[k]), ptr(r.Ptrs[k+1]))
+		}
+	case btreedb5.LeafNode:
+		fmt.Printf("block %d: leaf\n", r.Ptr)
+		fmt.Printf("chain:")
+		for _, p := range r.Chain {
+			fmt.Printf(" %d", p)
+		}
+		fmt.Println()
+		fmt.Printf("entries: %d\n", len(r.Keys))
+		for k := range r.Keys {
+			fmt.Printf("\t%s\tkey %d\tvalue %d\n", hex.EncodeToString(r.Keys[k]), len(r.Keys[k]), r.DataLens[k])
+		}
+		if r.Err != nil {
+			fmt.Printf("error: %v\n", r.Err)
+		}
+	case btreedb5.FreeNode:
+		fmt.Printf("block %d: free\n", r.Ptr)
+		fmt.Printf("next: %s\n", ptr(r.Next))
+		fmt.Printf("entries: %d\n", len(r.Ptrs))
+		for _, p := range r.Ptrs {
+			fmt.Printf("\t%d\n", p)
+		}
+	}
+}
+
+func printTrace(h *btreedb5.BTreeDB5, key string) {
+	k, e := hex.DecodeString(key)
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	if len(k) != h.KeySize {
+		log.Fatalf("key size is not %d\n", h.KeySize)
+	}
+
+	steps, e := h.Trace(k)
+
+	for _, s := range steps {
+		switch s.Type {
+		case btreedb5.IndexNode:
+			fmt.Printf("index %d\theight %d\tchild %d\n", s.Ptr, s.Height, s.Index)
+		case btreedb5.LeafNode:
+			fmt.Printf("leaf %d\tentry %d\tfound %v\n", s.Ptr, s.Index, s.Found)
+		}
+	}
+
+	if e != nil {
+		log.Fatalf("%+v\n", e)
+	}
+}
+
+func main() {
+	var in, mode, key string
+	var blk uint
+	flag.StringVar(&in, "i", "input", "db file")
+	flag.StringVar(&mode, "m", "header", "header/block/trace")
+	flag.UintVar(&blk, "b", 0, "block number, for block mode")
+	flag.StringVar(&key, "k", "", "key in hex, for trace mode")
+	flag.Parse()
+	log.SetFlags(log.Llongfile)
+
+	h, e := btreedb5.Load(in)
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	switch mode {
+	case "header":
+		printHeader(h)
+	case "block":
+		printBlock(h, blk)
+	case "trace":
+		printTrace(h, key)
+	default:
+		log.Fatalf("unknown mode %s\n", mode)
+	}
+}
@@ -7,129 +7,168 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"runtime"
+	"sync"
 
+	"github.com/pkg/errors"
 	"github.com/xhebox/bstruct/byteorder"
 	"github.com/xhebox/sbutils/lib/btreedb5"
 	"github.com/xhebox/sbutils/lib/sbvj01"
 )
 
-func main() {
-	var in, mode string
-	flag.StringVar(&in, "i", "input", "input file")
-	flag.StringVar(&mode, "m", "default", "default/records")
-	flag.Parse()
-	log.SetFlags(log.Llongfile)
+type record struct {
+	key  btreedb5.Key
+	data []byte
 
-	h, e := btreedb5.Load(in)
+	name string
+	out  []byte
+	err  error
+	done chan struct{}
+}
+
+func decode(r *record) {
+	z, e := zlib.NewReader(bytes.NewReader(r.data))
 	if e != nil {
-		log.Fatalln(e)
+		r.err = errors.Wrapf(e, "fail to decompress")
+		return
 	}
-	defer h.Close()
+	defer z.Close()
 
-	switch mode {
-	default:
-		e = h.Ascend(func(key btreedb5.Key, data []byte) {
-			z, e := zlib.NewReader(bytes.NewReader(data))
-			if e != nil {
-				log.Fatalln(e)
-			}
+	switch r.key[0] {
+	case 0:
+		r.name = "metadata"
 
-			switch key[0] {
-			case 0:
-				f, e := os.OpenFile("metadata", os.O_RDWR|os.O_CREATE, 0644)
-				if e != nil {
-					log.Fatalln(e)
-				}
-
-				x, e := byteorder.Uint32(z, byteorder.BigEndian)
-				if e != nil {
-					log.Fatalln(e)
-				}
-
-				y, e := byteorder.Uint32(z, byteorder.BigEndian)
-				if e != nil {
-					log.Fatalln(e)
-				}
+		data, e := ioutil.ReadAll(z)
+		if e != nil {
+			r.err = e
+			return
+		}
 
-				hdr, e := sbvj01.ReadHdr(z)
-				if e != nil {
-					log.Fatalln(e)
-				}
+		if len(data) < 8 {
+			r.err = errors.New("metadata too short")
+			return
+		}
 
-				body, e := sbvj01.Read(z)
-				if e != nil {
-					log.Fatalln(e)
-				}
+		docs, _, e := sbvj01.ReadFileBuf(data[8:], sbvj01.VJ)
+		if e != nil {
+			r.err = e
+			return
+		}
 
-				json, e := json.MarshalIndent(map[string]interface{}{
-					"size": []uint32{x, y},
-					"hdr":  hdr,
-					"body": body,
-				}, "", "\t")
-				if e != nil {
-					log.Fatalln(e)
-				}
+		r.out, r.err = json.MarshalIndent(map[string]interface{}{
+			"size": []uint32{
+				byteorder.BigEndian.Uint32(data[0:]),
+				byteorder.BigEndian.Uint32(data[4:]),
+			},
+			"hdr":  docs[0].Header,
+			"body": sbvj01.JSONValue(docs[0].Body),
+		}, "", "\t")
+	case 2:
+		r.name = fmt.Sprintf("type2_%s", hex.EncodeToString(r.key[1:]))
+
+		data, e := ioutil.ReadAll(z)
+		if e != nil {
+			r.err = e
+			return
+		}
 
-				f.Write(json)
+		docs, _, e := sbvj01.ReadFileBuf(data, sbvj01.NVJ)
+		if e != nil {
+			r.err = e
+			return
+		}
 
-				f.Close()
-			case 2:
-				f, e := os.OpenFile(fmt.Sprintf("type2_%s", hex.EncodeToString(key[1:])), os.O_RDWR|os.O_CREATE, 0644)
-				if e != nil {
-					log.Fatalln(e)
-				}
+		for k := range docs {
+			docs[k].Body = sbvj01.JSONValue(docs[k].Body)
+		}
 
-				cnt, e := byteorder.UVarint(z, byteorder.BigEndian)
-				if e != nil {
-					log.Fatalln(e)
-				}
+		r.out, r.err = json.MarshalIndent(docs, "", "\t")
+	default:
+		r.name = fmt.Sprintf("data_%s", hex.EncodeToString(r.key))
 
-				vjs := []map[string]interface{}{}
+		r.out, r.err = ioutil.ReadAll(z)
+	}
+}
 
-				for i, j := 0, int(cnt); i < j; i++ {
-					hdr, e := sbvj01.ReadHdr(z)
-					if e != nil {
-						log.Fatalln(e)
-					}
+func main() {
+	var in, mode string
+	var jobs int
+	flag.StringVar(&in, "i", "input", "input file")
+	flag.StringVar(&mode, "m", "default", "default/records")
+	flag.IntVar(&jobs, "j", runtime.NumCPU(), "number of decoding workers")
+	flag.Parse()
+	log.SetFlags(log.Llongfile)
 
-					body, e := sbvj01.Read(z)
-					if e != nil {
-						log.Fatalln(e)
-					}
+	if jobs < 1 {
+		jobs = 1
+	}
 
-					vjs = append(vjs, map[string]interface{}{
-						"hdr":  hdr,
-						"body": body,
-					})
-				}
+	h, e := btreedb5.Load(in)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	defer h.Close()
 
-				json, e := json.MarshalIndent(vjs, "", "\t")
-				if e != nil {
-					fmt.Printf("%v %+v\n", e, vjs)
-					log.Fatalln(e)
+	switch mode {
+	default:
+		// records are handed to the workers in tree order, and the same
+		// order is kept in pending, so the writer below handles them
+		// deterministically no matter which worker finishes first.
+		work := make(chan *record, jobs)
+		pending := make(chan *record, 2*jobs)
+
+		var wg sync.WaitGroup
+		for i := 0; i < jobs; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for r := range work {
+					decode(r)
+					close(r.done)
 				}
+			}()
+		}
 
-				f.Write(json)
+		failed := 0
+		written := make(chan struct{})
+		go func() {
+			for r := range pending {
+				<-r.done
 
-				f.Close()
-			default:
-				f, e := os.OpenFile(fmt.Sprintf("data_%s", hex.EncodeToString(key)), os.O_RDWR|os.O_CREATE, 0644)
-				if e != nil {
-					log.Fatalln(e)
+				if r.err == nil {
+					r.err = ioutil.WriteFile(r.name, r.out, 0644)
 				}
 
-				io.Copy(f, z)
-
-				f.Close()
+				if r.err != nil {
+					log.Printf("skip record %s: %+v\n", hex.EncodeToString(r.key), r.err)
+					failed++
+				}
 			}
+			close(written)
+		}()
 
-			z.Close()
+		e = h.Ascend(func(key btreedb5.Key, data []byte) {
+			r := &record{key: key, data: data, done: make(chan struct{})}
+			pending <- r
+			work <- r
 		})
+
+		close(work)
+		close(pending)
+		wg.Wait()
+		<-written
+
 		if e != nil {
 			log.Fatalf("%+v\n", e)
 		}
+
+		if failed != 0 {
+			log.Printf("%d records failed\n", failed)
+			h.Close()
+			os.Exit(1)
+		}
 	}
 }
@@ -9,32 +9,42 @@ import (
 	"log"
 	"os"
 
-	"github.com/xhebox/bstruct/byteorder"
 	"github.com/xhebox/sbutils/lib/sbvj01"
 )
 
 func main() {
 	var in, out, mode string
 	var skip int
+	var tagged bool
 	flag.StringVar(&in, "i", "input", "versioned json file")
 	flag.StringVar(&out, "o", "stdout", "output json")
-	flag.StringVar(&mode, "m", "vj", "vjmagic/vj/raw/nvj")
+	flag.StringVar(&mode, "m", "auto", "auto/vjmagic/vj/raw/nvj")
 	flag.IntVar(&skip, "n", 0, "skip first n bytes")
+	flag.BoolVar(&tagged, "t", false, "tagged json, keeps number types and escapes strings")
 	flag.Parse()
 	log.SetFlags(log.Llongfile)
 
+	c, e := sbvj01.ParseContainer(mode)
+	if e != nil {
+		log.Fatalln(e)
+	}
+
 	contents, e := ioutil.ReadFile(in)
 	if e != nil {
 		log.Fatalln(e)
 	}
 
+	if skip > len(contents) {
+		log.Fatalln("skip beyond the end of file")
+	}
+
 	contents = contents[skip:]
 
 	var outwt io.Writer
 	if out == "stdout" {
 		outwt = os.Stdout
 	} else {
-		f, e := os.OpenFile(out, os.O_CREATE|os.O_RDWR, 0644)
+		f, e := os.OpenFile(out, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
 		if e != nil {
 			log.Fatalln(e)
 		}
@@ -43,68 +53,37 @@ func main() {
 		outwt = f
 	}
 
-	rd := bytes.NewReader(contents)
-
-	switch mode {
-	case "raw":
-		r, e := sbvj01.Read(rd, byteorder.BigEndian)
-		if e != nil {
-			log.Fatalln(e)
-		}
-
-		out, e := json.MarshalIndent(r, "", "\t")
-		if e != nil {
-			log.Fatalln(e)
-		}
-
-		_, e = io.Copy(outwt, bytes.NewReader(out))
-		if e != nil {
-			log.Fatalln(e)
-		}
-	case "vj":
-		r, e := sbvj01.ReadHdr(rd)
-		if e != nil {
-			log.Fatalln(e)
-		}
-
-		b, e := sbvj01.Read(rd, r.Endian)
-		if e != nil {
-			log.Fatalln(e)
-		}
-
-		out, e := json.MarshalIndent(b, "", "\t")
-		if e != nil {
-			log.Fatalln(e)
-		}
-
-		_, e = io.Copy(outwt, bytes.NewReader(out))
-		if e != nil {
-			log.Fatalln(e)
-		}
-	case "nvj":
-		r := int(int8(contents[0]))
-		rd = bytes.NewReader(contents[1:])
+	var docs []sbvj01.Document
+	if tagged {
+		docs, c, e = sbvj01.ReadFileTagged(bytes.NewReader(contents), c)
+	} else {
+		docs, c, e = sbvj01.ReadFileBuf(contents, c)
+	}
+	if e != nil {
+		log.Fatalln(e)
+	}
 
-		for i := 0; i < r; i++ {
-			r, e := sbvj01.ReadHdr(rd)
-			if e != nil {
-				log.Fatalln(e)
-			}
+	for k := range docs {
+		docs[k].Body = sbvj01.JSONValue(docs[k].Body)
+	}
 
-			b, e := sbvj01.Read(rd, r.Endian)
-			if e != nil {
-				log.Fatalln(e)
-			}
+	var r interface{}
+	switch c {
+	case sbvj01.Raw:
+		r = docs[0].Body
+	case sbvj01.NVJ:
+		r = docs
+	default:
+		r = docs[0]
+	}
 
-			out, e := json.MarshalIndent(b, "", "\t")
-			if e != nil {
-				log.Fatalln(e)
-			}
+	res, e := json.MarshalIndent(r, "", "\t")
+	if e != nil {
+		log.Fatalln(e)
+	}
 
-			_, e = io.Copy(outwt, bytes.NewReader(out))
-			if e != nil {
-				log.Fatalln(e)
-			}
-		}
+	_, e = io.Copy(outwt, bytes.NewReader(res))
+	if e != nil {
+		log.Fatalln(e)
 	}
 }
@@ -1,14 +1,13 @@
 package main
 
 import (
-	"bytes"
-	"compress/zlib"
 	"fmt"
 	"io"
 	"io/ioutil"
 
 	"github.com/pkg/errors"
 	"github.com/xhebox/bstruct/byteorder"
+	"github.com/xhebox/sbutils/lib/data_types"
 	"github.com/xhebox/sbutils/lib/packet"
 )
 
@@ -24,52 +23,28 @@ type Filter struct {
 	// filter function
 	cb  FilterCallback
 	End byteorder.ByteOrder
+	// MaxLength bounds a packet, so that a peer can not exhaust memory. 0
+	// means no limit.
+	MaxLength uint64
 }
 
+// DefaultMaxLength is the MaxLength of NewFilter.
+const DefaultMaxLength = 1 << 26
+
 func NewFilter(in io.Reader, out io.Writer, cb FilterCallback) *Filter {
 	r := &Filter{}
 	r.in = in
 	r.out = out
 	r.cb = cb
 	r.End = byteorder.BigEndian
+	r.MaxLength = DefaultMaxLength
 	return r
 }
 
+// ReadPacket reads the next packet, refusing one longer than MaxLength
+// before or after inflating it.
 func (r *Filter) ReadPacket() (*packet.BasePacket, error) {
-	pktType, e := byteorder.Uint8(r.in)
-	if e != nil {
-		return nil, errors.WithStack(e)
-	}
-
-	length, e := byteorder.Varint(r.in, r.End)
-	if e != nil {
-		return nil, errors.WithStack(e)
-	}
-	abslength := int64(length)
-	if abslength < 0 {
-		abslength = -abslength
-	}
-
-	buf := &bytes.Buffer{}
-
-	in := io.LimitReader(r.in, abslength)
-
-	if length < 0 {
-		zin, e := zlib.NewReader(in)
-		if e != nil {
-			return nil, errors.WithStack(e)
-		}
-
-		defer zin.Close()
-		in = zin
-	}
-
-	_, e = io.Copy(buf, in)
-	if e != nil {
-		return nil, errors.WithStack(e)
-	}
-
-	return &packet.BasePacket{Type: pktType, Buf: buf.Bytes()}, nil
+	return packet.ReadPacket(data_types.LimitLength(r.in, r.MaxLength), r.End)
 }
 
 func (r *Filter) WritePacket(pkt *packet.BasePacket) error {
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/xhebox/bstruct/byteorder"
+	"github.com/xhebox/sbutils/lib/data_types"
+	"github.com/xhebox/sbutils/lib/packet"
+)
+
+func TestReadPacketLimit(t *testing.T) {
+	read := func(data []byte, max uint64) (*packet.BasePacket, error) {
+		f := NewFilter(bytes.NewReader(data), nil, nil)
+		f.MaxLength = max
+		return f.ReadPacket()
+	}
+
+	// an empty packet is written as a zero length, not compressed
+	if p, e := read([]byte{3, 0}, 1<<12); e != nil || p.Type != 3 || len(p.Buf) != 0 {
+		t.Fatalf("empty: %+v", e)
+	}
+
+	// small, then zeros that compress well below the limit
+	for _, size := range []int{16, 1 << 12} {
+		buf := &bytes.Buffer{}
+		if e := packet.WritePacket(buf, byteorder.BigEndian, &packet.BasePacket{Type: 3, Buf: make([]byte, size)}); e != nil {
+			t.Fatal(e)
+		}
+
+		if p, e := read(buf.Bytes(), 1<<12); e != nil || p.Type != 3 || len(p.Buf) != size {
+			t.Fatalf("%d: %+v", size, e)
+		}
+
+		if size > 16 && buf.Len() > 64 {
+			t.Fatalf("%d bytes were not compressed", size)
+		}
+
+		if _, e := read(buf.Bytes(), uint64(size-1)); !isTooLarge(e) {
+			t.Fatalf("%d inflated past the limit: %v", size, e)
+		}
+
+		if _, e := read(buf.Bytes(), 0); e != nil {
+			t.Fatalf("%d without a limit: %v", size, e)
+		}
+	}
+
+	// a compressed length past the limit is refused before inflating
+	z := &bytes.Buffer{}
+	zw := zlib.NewWriter(z)
+	zw.Write(make([]byte, 100))
+	zw.Close()
+
+	data := &bytes.Buffer{}
+	data.WriteByte(3)
+	byteorder.PutVarint(data, byteorder.BigEndian, -int64(z.Len()))
+	data.Write(z.Bytes())
+
+	if _, e := read(data.Bytes(), uint64(z.Len()-1)); !isTooLarge(e) {
+		t.Fatalf("compressed length past the limit: %v", e)
+	}
+
+	if _, e := read([]byte{3, 0x81, 0x80, 0x80, 0}, 1<<10); !isTooLarge(e) {
+		t.Fatalf("length past the limit: %v", e)
+	}
+}
+
+func isTooLarge(e error) bool {
+	_, ok := errors.Cause(e).(*data_types.ErrTooLarge)
+	return ok
+}
@@ -0,0 +1,51 @@
+package atomicfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile replaces file by a temporary file written in the same directory,
+// so that a failure never leaves it half written. The permissions of an
+// existing file are kept.
+func WriteFile(file string, data []byte) error {
+	mode := os.FileMode(0644)
+	if st, e := os.Stat(file); e == nil {
+		mode = st.Mode().Perm()
+	}
+
+	f, e := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file)+".")
+	if e != nil {
+		return e
+	}
+
+	e = write(f, data, mode)
+	if e == nil {
+		e = os.Rename(f.Name(), file)
+	}
+
+	if e != nil {
+		os.Remove(f.Name())
+	}
+
+	return e
+}
+
+func write(f *os.File, data []byte, mode os.FileMode) error {
+	if _, e := f.Write(data); e != nil {
+		f.Close()
+		return e
+	}
+
+	if e := f.Sync(); e != nil {
+		f.Close()
+		return e
+	}
+
+	if e := f.Close(); e != nil {
+		return e
+	}
+
+	return os.Chmod(f.Name(), mode)
+}
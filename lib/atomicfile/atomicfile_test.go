@@ -0,0 +1,61 @@
+package atomicfile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+
+	if e := WriteFile(file, []byte("a")); e != nil {
+		t.Fatal(e)
+	}
+
+	if st, e := os.Stat(file); e != nil || st.Mode().Perm() != 0644 {
+		t.Fatalf("new file: %v %v", st, e)
+	}
+
+	if e := os.Chmod(file, 0600); e != nil {
+		t.Fatal(e)
+	}
+
+	if e := WriteFile(file, []byte("b")); e != nil {
+		t.Fatal(e)
+	}
+
+	if st, e := os.Stat(file); e != nil || st.Mode().Perm() != 0600 {
+		t.Fatalf("expect the mode to be kept: %v %v", st, e)
+	}
+
+	if b, e := ioutil.ReadFile(file); e != nil || !bytes.Equal(b, []byte("b")) {
+		t.Fatalf("got %q %v", b, e)
+	}
+}
+
+func TestWriteFileCleanup(t *testing.T) {
+	dir := t.TempDir()
+
+	// a directory that is not empty can not be replaced by a file
+	file := filepath.Join(dir, "file")
+	if e := os.MkdirAll(filepath.Join(file, "sub"), 0755); e != nil {
+		t.Fatal(e)
+	}
+
+	if e := WriteFile(file, []byte("a")); e == nil {
+		t.Fatal("expect the rename to fail")
+	}
+
+	names, e := ioutil.ReadDir(dir)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if len(names) != 1 || names[0].Name() != "file" {
+		t.Fatalf("expect the temporary file to be removed, got %v", names)
+	}
+}
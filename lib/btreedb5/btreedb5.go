@@ -28,6 +28,8 @@ const (
 var (
 	Magic = []byte{'B', 'T', 'r', 'e', 'e', 'D', 'B', '5'}
 	zero  = []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	ErrInvalidGeometry = errors.New("invalid geometry")
 )
 
 type BTree struct {
@@ -51,6 +53,8 @@ type BTreeDB5 struct {
 	freemax          int
 	leafmax          int
 	freemu           sync.Mutex
+	savepoints       []*Savepoint
+	held             []uint
 	file             *blockfile.BlockFile
 }
 
@@ -62,33 +66,79 @@ func freemax(blksz int) int {
 	return (blksz - 2 - 4 - 4) / 4
 }
 
-func New(file string, ident string, blksz, keysz int) (h *BTreeDB5, e error) {
+// Options describes the database created by Create.
+type Options struct {
+	Identifier string
+	BlockSize  int
+	KeySize    int
+	Overwrite  bool // replace an existing file instead of failing
+}
+
+// validate checks that the geometry fits the node layout: a free node must
+// hold at least one pointer, and an index node at least two keys.
+func (o Options) validate() error {
+	switch {
+	case len(o.Identifier) > 16:
+		return errors.Wrapf(ErrInvalidGeometry, "identifier %q longer than 16 bytes", o.Identifier)
+	case o.KeySize < 1:
+		return errors.Wrapf(ErrInvalidGeometry, "key size %d", o.KeySize)
+	case o.BlockSize < 0 || int64(o.BlockSize) > int64(^uint32(0)>>1):
+		return errors.Wrapf(ErrInvalidGeometry, "block size %d", o.BlockSize)
+	case freemax(o.BlockSize) < 1:
+		return errors.Wrapf(ErrInvalidGeometry, "block size %d can not hold a free node", o.BlockSize)
+	case intermax(o.BlockSize, o.KeySize) < 3:
+		return errors.Wrapf(ErrInvalidGeometry, "block size %d can not hold two keys of size %d in an index node", o.BlockSize, o.KeySize)
+	}
+
+	return nil
+}
+
+func (h *BTreeDB5) init() {
+	h.used_uncommitted = make(map[uint]bool)
+	h.free_committed = make(map[uint]bool)
+	h.free_uncommitted = make(map[uint]bool)
+	h.freemax = freemax(h.BlockSize)
+	h.intermax = intermax(h.BlockSize, h.KeySize)
+	h.leafmax = 2
+}
+
+// Create creates a new database at file. It fails if file already exists,
+// unless opts.Overwrite is set, and if the geometry is invalid.
+func Create(file string, opts Options) (h *BTreeDB5, e error) {
+	if e := opts.validate(); e != nil {
+		return nil, e
+	}
+
 	h = &BTreeDB5{
-		Identifier: ident,
+		Identifier: opts.Identifier,
 		UseAltRoot: false,
 		Tree: BTree{
 			FreeIndex:  maxptr,
 			RootBlock:  maxptr,
 			RootIsLeaf: true,
 		},
-		BlockSize:        blksz,
-		KeySize:          keysz,
-		used_uncommitted: make(map[uint]bool),
-		free_committed:   make(map[uint]bool),
-		free_uncommitted: make(map[uint]bool),
-		freemax:          freemax(blksz),
-		intermax:         intermax(blksz, keysz),
-		leafmax:          2,
+		BlockSize: opts.BlockSize,
+		KeySize:   opts.KeySize,
+	}
+	h.init()
+
+	flag := os.O_CREATE | os.O_RDWR | os.O_EXCL
+	if opts.Overwrite {
+		flag = os.O_CREATE | os.O_RDWR | os.O_TRUNC
 	}
 
-	os.Remove(file)
+	f, e := os.OpenFile(file, flag, 0644)
+	if e != nil {
+		return nil, errors.Wrapf(e, "failed to create the file")
+	}
+	f.Close()
 
 	h.file, e = blockfile.NewBlockFile(file, 512)
 	if e != nil {
 		return nil, errors.Wrapf(e, "failed to open a block file")
 	}
 
-	h.file.SetBlksz(blksz)
+	h.file.SetBlksz(h.BlockSize)
 
 	if e := h.file.Resize(0); e != nil {
 		return nil, errors.Wrapf(e, "failed to resize the block file")
@@ -101,6 +151,19 @@ func New(file string, ident string, blksz, keysz int) (h *BTreeDB5, e error) {
 	return h, nil
 }
 
+// New creates a new database at file, removing any existing file.
+//
+// Deprecated: use Create, which refuses to overwrite by default and
+// validates the geometry.
+func New(file string, ident string, blksz, keysz int) (h *BTreeDB5, e error) {
+	return Create(file, Options{
+		Identifier: ident,
+		BlockSize:  blksz,
+		KeySize:    keysz,
+		Overwrite:  true,
+	})
+}
+
 func Load(file string) (h *BTreeDB5, e error) {
 	h = &BTreeDB5{}
 
@@ -111,6 +174,13 @@ func Load(file string) (h *BTreeDB5, e error) {
 
 	h.unmarshalHeader()
 
+	if e := (Options{BlockSize: h.BlockSize, KeySize: h.KeySize}).validate(); e != nil {
+		h.file.Close()
+		return nil, e
+	}
+
+	h.init()
+
 	h.file.SetBlksz(h.BlockSize)
 
 	h.readRoot()
@@ -289,17 +359,27 @@ func (h *BTreeDB5) freelist_push(ptr uint) {
 	h.freemu.Lock()
 
 	if ptr != maxptr {
-		if h.used_uncommitted[ptr] {
-			h.used_uncommitted[ptr] = false
-			h.free_committed[ptr] = true
+		if len(h.savepoints) != 0 {
+			// the block may still be reachable from a savepoint root, it
+			// must not be reused before the savepoint goes away.
+			h.held = append(h.held, ptr)
 		} else {
-			h.free_uncommitted[ptr] = true
+			h.freelist_free(ptr)
 		}
 	}
 
 	h.freemu.Unlock()
 }
 
+func (h *BTreeDB5) freelist_free(ptr uint) {
+	if h.used_uncommitted[ptr] {
+		h.used_uncommitted[ptr] = false
+		h.free_committed[ptr] = true
+	} else {
+		h.free_uncommitted[ptr] = true
+	}
+}
+
 func (h *BTreeDB5) freelist_gpop() (uint, bool) {
 	r := h.file.Cap()
 
@@ -364,6 +444,8 @@ func (h *BTreeDB5) freelist_clear() {
 	for k := range m {
 		delete(m, k)
 	}
+	h.savepoints = nil
+	h.held = nil
 	h.freemu.Unlock()
 }
 
@@ -433,6 +515,7 @@ func (h *BTreeDB5) Commit() (e error) {
 		}
 	}()
 
+	h.freelist_release()
 	h.writeRoot()
 	h.UseAltRoot = !h.UseAltRoot
 	h.commit(h.free_uncommitted)
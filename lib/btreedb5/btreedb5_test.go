@@ -1,5 +1,15 @@
 package btreedb5
 
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
 /*
 func TestApi(b *testing.T) {
 	h, e := New("test2", "fuck", 512, 5)
@@ -40,3 +50,176 @@ func TestApi(b *testing.T) {
 	}
 }
 */
+
+func TestCreate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "db")
+
+	for _, opts := range []Options{
+		{Identifier: "World4", BlockSize: 2048, KeySize: 0},
+		{Identifier: "World4", BlockSize: 12, KeySize: 5},
+		{Identifier: "World4", BlockSize: 28, KeySize: 5},
+		{Identifier: "an identifier too long", BlockSize: 2048, KeySize: 5},
+	} {
+		if _, e := Create(file, opts); errors.Cause(e) != ErrInvalidGeometry {
+			t.Fatalf("%+v: expect invalid geometry, got %v", opts, e)
+		}
+	}
+
+	if _, e := os.Stat(file); !os.IsNotExist(e) {
+		t.Fatal("invalid geometry should not create the file")
+	}
+
+	opts := Options{Identifier: "World4", BlockSize: 512, KeySize: 5}
+
+	h, e := Create(file, opts)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if e := h.Insert(Key{1, 2, 3, 4, 5}, []byte{6}); e != nil {
+		t.Fatal(e)
+	}
+
+	if e := h.Close(); e != nil {
+		t.Fatal(e)
+	}
+
+	if _, e := Create(file, opts); !os.IsExist(errors.Cause(e)) {
+		t.Fatalf("expect the file to exist, got %v", e)
+	}
+
+	h, e = Load(file)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if e := h.Insert(Key{1, 2, 3, 4, 6}, []byte{7}); e != nil {
+		t.Fatal(e)
+	}
+
+	if e := h.Close(); e != nil {
+		t.Fatal(e)
+	}
+
+	opts.Overwrite = true
+
+	h, e = Create(file, opts)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer h.Close()
+
+	if ok, _ := h.Has(Key{1, 2, 3, 4, 5}); ok {
+		t.Fatal("expect an empty database after overwriting")
+	}
+}
+
+func TestInspect(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "db")
+
+	h, e := Create(file, Options{Identifier: "World4", BlockSize: 64, KeySize: 4})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// the second commit rewrites nodes, freeing the blocks of the first
+	for k := 0; k < 300; k++ {
+		if e := h.Insert(Key{0, 0, byte(k >> 8), byte(k)}, []byte{byte(k)}); e != nil {
+			t.Fatal(e)
+		}
+
+		if k == 150 {
+			if e := h.Commit(); e != nil {
+				t.Fatal(e)
+			}
+		}
+	}
+
+	if e := h.Close(); e != nil {
+		t.Fatal(e)
+	}
+
+	h, e = Load(file)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer h.Close()
+
+	hdr := h.Header()
+	// the identifier keeps the padding of its fixed size field
+	if strings.TrimRight(hdr.Identifier, " \x00") != "World4" || hdr.BlockSize != 64 || hdr.KeySize != 4 || hdr.UseAltRoot != h.UseAltRoot {
+		t.Fatalf("unexpected header %+v", hdr)
+	}
+
+	root := hdr.Roots[0]
+	if hdr.UseAltRoot {
+		root = hdr.Roots[1]
+	}
+
+	if root.RootBlock != h.Tree.RootBlock || root.RootIsLeaf || root.FreeIndex != h.Tree.FreeIndex {
+		t.Fatalf("root %+v does not match the tree %+v", root, h.Tree)
+	}
+
+	if fi, e := os.Stat(file); e != nil || root.DeviceSize != fi.Size() {
+		t.Fatalf("device size %d, file %v %v", root.DeviceSize, fi, e)
+	}
+
+	b, e := h.Block(root.RootBlock)
+	if e != nil || b.Type != IndexNode || len(b.Keys) == 0 || len(b.Ptrs) != len(b.Keys)+1 {
+		t.Fatalf("root block %+v %v", b, e)
+	}
+
+	free := 0
+	for ptr := root.FreeIndex; ptr != maxptr; ptr = b.Next {
+		b, e = h.Block(ptr)
+		if e != nil || b.Type != FreeNode {
+			t.Fatalf("free block %d: %+v %v", ptr, b, e)
+		}
+
+		free += len(b.Ptrs)
+	}
+
+	if free == 0 {
+		t.Fatal("expect the first commit to leave free blocks")
+	}
+
+	key := Key{0, 0, 0, 42}
+
+	trace, e := h.Trace(key)
+	if e != nil || len(trace) < 2 || trace[0].Ptr != root.RootBlock {
+		t.Fatalf("trace %+v %v", trace, e)
+	}
+
+	for k, step := range trace[:len(trace)-1] {
+		b, e := h.Block(step.Ptr)
+		if e != nil || step.Type != IndexNode || b.Type != IndexNode || b.Height != step.Height {
+			t.Fatalf("step %d %+v: %+v %v", k, step, b, e)
+		}
+
+		if next := trace[k+1].Ptr; b.Ptrs[step.Index] != next {
+			t.Fatalf("step %d goes to %d, not child %d", k, next, step.Index)
+		}
+	}
+
+	leaf := trace[len(trace)-1]
+	if leaf.Type != LeafNode || !leaf.Found {
+		t.Fatalf("leaf step %+v", leaf)
+	}
+
+	b, e = h.Block(leaf.Ptr)
+	if e != nil || b.Err != nil || b.Type != LeafNode || len(b.Chain) == 0 || b.Chain[0] != leaf.Ptr {
+		t.Fatalf("leaf block %+v %v", b, e)
+	}
+
+	if !bytes.Equal(b.Keys[leaf.Index], key) || b.DataLens[leaf.Index] != 1 {
+		t.Fatalf("entry %d of %+v is not %v", leaf.Index, b, key)
+	}
+
+	if trace, e := h.Trace(Key{0, 0, 2, 0}); e != nil || trace[len(trace)-1].Found {
+		t.Fatalf("trace of a missing key %+v %v", trace, e)
+	}
+
+	if _, e := h.Block(h.file.Cap()); e == nil {
+		t.Fatal("expect a block out of range to fail")
+	}
+}
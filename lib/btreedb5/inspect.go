@@ -0,0 +1,213 @@
+package btreedb5
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"github.com/xhebox/bstruct/byteorder"
+)
+
+// RootInfo is one of the two root descriptors stored in the header.
+type RootInfo struct {
+	FreeIndex  uint
+	DeviceSize int64
+	RootBlock  uint
+	RootIsLeaf bool
+}
+
+// HeaderInfo is the decoded header, as stored on disk.
+type HeaderInfo struct {
+	Identifier string
+	BlockSize  int
+	KeySize    int
+	UseAltRoot bool
+	Roots      [2]RootInfo
+}
+
+// BlockInfo is the decoded content of one block. Which fields are set
+// depends on Type:
+//
+// IndexNode: Height, Keys and Ptrs, the child pointers.
+// LeafNode: Chain, the blocks of the leaf starting at Ptr, and for each entry
+// Keys and DataLens. Err is set if the entries do not decode, which is normal
+// for a block in the middle of a chain.
+// FreeNode: Next and Ptrs, the free blocks.
+type BlockInfo struct {
+	Ptr      uint
+	Type     byte
+	Height   uint8
+	Keys     []Key
+	Ptrs     []uint
+	Next     uint
+	Chain    []uint
+	DataLens []int
+	Err      error
+}
+
+// TraceStep is one node visited while looking up a key.
+type TraceStep struct {
+	Ptr    uint
+	Type   byte
+	Height uint8
+	Index  int  // child taken in an index node, entry position in a leaf
+	Found  bool // only meaningful for the leaf
+}
+
+// Header decodes the header, including the root descriptor not in use.
+func (h *BTreeDB5) Header() HeaderInfo {
+	hdr := h.file.Header()
+
+	r := HeaderInfo{
+		Identifier: h.Identifier,
+		BlockSize:  h.BlockSize,
+		KeySize:    h.KeySize,
+		UseAltRoot: byteorder.Byte2Bool(hdr[32]),
+	}
+
+	for k, off := range []int{33, 50} {
+		r.Roots[k] = RootInfo{
+			FreeIndex:  uint(byteorder.BigEndian.Uint32(hdr[off:])),
+			DeviceSize: byteorder.BigEndian.Int64(hdr[off+4:]),
+			RootBlock:  uint(byteorder.BigEndian.Uint32(hdr[off+12:])),
+			RootIsLeaf: byteorder.Byte2Bool(hdr[off+16]),
+		}
+	}
+
+	return r
+}
+
+// Block decodes the block ptr without assuming where it sits in the tree.
+func (h *BTreeDB5) Block(ptr uint) (r *BlockInfo, e error) {
+	defer func() {
+		k := recover()
+		if k != nil {
+			e = errors.Errorf("%+v\n", k)
+		}
+	}()
+
+	if ptr >= h.file.Cap() {
+		return nil, errors.Errorf("block %d out of range, %d blocks", ptr, h.file.Cap())
+	}
+
+	block := h.file.Block(ptr)
+
+	r = &BlockInfo{Ptr: ptr}
+
+	if block[0] != block[1] {
+		return r, errors.Errorf("unknown signature %q", block[:2])
+	}
+
+	r.Type = block[0]
+
+	switch r.Type {
+	case IndexNode:
+		node := h.indexNode(ptr)
+		r.Height = node.height
+		r.Keys = node.keys
+		r.Ptrs = node.ptrs
+	case FreeNode:
+		node := h.freeNode(ptr)
+		r.Next = node.next
+		r.Ptrs = node.ptrs
+	case LeafNode:
+		r.Chain, r.Keys, r.DataLens, r.Err = h.inspectLeaf(ptr)
+	default:
+		return r, errors.Errorf("unknown signature %q", block[:2])
+	}
+
+	return r, nil
+}
+
+func (h *BTreeDB5) inspectLeaf(ptr uint) (chain []uint, keys []Key, lens []int, e error) {
+	readers := []io.Reader{}
+	seen := map[uint]bool{}
+
+	for ptr != maxptr {
+		if seen[ptr] {
+			return chain, nil, nil, errors.Errorf("chain loops back to block %d", ptr)
+		}
+		seen[ptr] = true
+
+		chain = append(chain, ptr)
+
+		if ptr >= h.file.Cap() {
+			return chain, nil, nil, errors.Errorf("block %d out of range", ptr)
+		}
+
+		block := h.file.Block(ptr)
+
+		if block[0] != LeafNode || block[1] != LeafNode {
+			return chain, nil, nil, errors.Errorf("block %d is not a leaf", ptr)
+		}
+
+		readers = append(readers, bytes.NewReader(block[2:h.BlockSize-4]))
+
+		ptr = uint(byteorder.BigEndian.Uint32(block[h.BlockSize-4:]))
+	}
+
+	rd := io.MultiReader(readers...)
+
+	N, e := byteorder.Uint32(rd, byteorder.BigEndian)
+	if e != nil {
+		return chain, nil, nil, e
+	}
+
+	for k := uint32(0); k < N; k++ {
+		key := make(Key, h.KeySize)
+
+		if _, e := io.ReadFull(rd, key); e != nil {
+			return chain, keys, lens, errors.Wrapf(e, "entry %d of %d", k, N)
+		}
+
+		u, e := byteorder.UVarint(rd, byteorder.BigEndian)
+		if e != nil {
+			return chain, keys, lens, errors.Wrapf(e, "entry %d of %d", k, N)
+		}
+
+		if _, e := io.CopyN(ioutil.Discard, rd, int64(u)); e != nil {
+			return chain, keys, lens, errors.Wrapf(e, "entry %d of %d", k, N)
+		}
+
+		keys = append(keys, key)
+		lens = append(lens, int(u))
+	}
+
+	return chain, keys, lens, nil
+}
+
+// Trace looks up key like Get does, and returns every node visited on the
+// way, from the root down to the leaf.
+func (h *BTreeDB5) Trace(key Key) (r []TraceStep, e error) {
+	defer func() {
+		k := recover()
+		if k != nil {
+			e = errors.Errorf("%+v\n", k)
+		}
+	}()
+
+	ptr := h.Tree.RootBlock
+	leaf := h.Tree.RootIsLeaf
+
+	for !leaf {
+		node := h.indexNode(ptr)
+
+		index, ok := node.find(key)
+		if ok {
+			index = index + 1
+		}
+
+		r = append(r, TraceStep{Ptr: ptr, Type: IndexNode, Height: node.height, Index: index})
+
+		ptr = node.ptrs[index]
+		leaf = node.height == 0
+	}
+
+	node := h.leafNode(ptr)
+	index, ok := node.find(key)
+
+	r = append(r, TraceStep{Ptr: ptr, Type: LeafNode, Index: index, Found: ok})
+
+	return r, nil
+}
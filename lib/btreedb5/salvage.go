@@ -0,0 +1,436 @@
+package btreedb5
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/xhebox/bstruct/byteorder"
+)
+
+// SalvageOptions overrides the geometry stored in the header of the damaged
+// file. Zero values mean "read it from the header".
+//
+// Overwrite replaces an existing file at the destination.
+//
+// Partial also keeps the entries decoded from broken leaf chains, i.e. chains
+// that point to a non-leaf block, loop, or whose contents do not parse up to
+// the last block, and from chains on the free list. Such chains are mostly
+// stale leaves, so their entries are only used for keys no live intact chain
+// has.
+type SalvageOptions struct {
+	Identifier string
+	BlockSize  int
+	KeySize    int
+	Overwrite  bool
+	Partial    bool
+}
+
+// SalvageReport sums up what a salvage run found.
+type SalvageReport struct {
+	Blocks     uint // blocks scanned
+	Leaves     int  // leaf chains found
+	Broken     int  // leaf chains that stopped parsing early
+	Freed      int  // leaf chains on the free list
+	FreeList   bool // whether the free list could be told apart from stale free blocks
+	Records    int  // distinct keys written
+	Duplicates int  // older copies dropped
+	Ambiguous  int  // keys with copies that could not be ordered, the first one found is kept
+}
+
+type salvageRecord struct {
+	broken bool
+	freed  bool
+	data   ByteArray
+}
+
+// newer reports whether r should replace o, and whether the two copies could
+// be ordered at all. A copy from an intact chain wins over one from a broken
+// chain, then a copy from a live chain over one from a chain on the free list,
+// since every block a commit frees is listed there until it is reused. Two
+// intact live copies only exist if the free list is lost, and then nothing
+// tells which one is newer.
+func (r *salvageRecord) newer(o *salvageRecord) (bool, bool) {
+	if r.broken != o.broken {
+		return !r.broken, true
+	}
+
+	if r.freed != o.freed {
+		return !r.freed, true
+	}
+
+	return false, false
+}
+
+type salvageFree struct {
+	next uint
+	ptrs []uint
+}
+
+// freelist finds the current free list among the free blocks of the file,
+// and returns the blocks it lists. A free block that was popped off the list
+// keeps its contents until it is reused, so pointers alone can not tell it
+// apart. But popping a free block frees it: the current list is the chain
+// that lists every free block outside of it, and none of its own. Every free
+// block is tried as the head, hint first. The second result is false if no
+// chain fits.
+func freelist(frees map[uint]*salvageFree, hint uint) (map[uint]bool, bool) {
+	if len(frees) == 0 {
+		return map[uint]bool{}, true
+	}
+
+	heads := make([]uint, 0, len(frees)+1)
+	heads = append(heads, hint)
+	for ptr := range frees {
+		heads = append(heads, ptr)
+	}
+	sort.Slice(heads[1:], func(i, j int) bool { return heads[i+1] < heads[j+1] })
+
+	for _, head := range heads {
+		chain := map[uint]bool{}
+		listed := map[uint]bool{}
+		ok := true
+
+		for ptr := head; ok && ptr != maxptr; {
+			f, found := frees[ptr]
+			if !found || chain[ptr] {
+				ok = false
+				break
+			}
+			chain[ptr] = true
+
+			for _, p := range f.ptrs {
+				listed[p] = true
+			}
+			ptr = f.next
+		}
+
+		for ptr := range frees {
+			if !ok || chain[ptr] == listed[ptr] {
+				ok = false
+				break
+			}
+		}
+
+		if ok {
+			return listed, true
+		}
+	}
+
+	return map[uint]bool{}, false
+}
+
+type salvager struct {
+	file   *os.File
+	hdrsz  int64
+	blksz  int
+	keysz  int
+	blks   uint
+	report SalvageReport
+}
+
+func (s *salvager) block(ptr uint) ([]byte, error) {
+	if ptr >= s.blks {
+		return nil, errors.Errorf("block %d out of range", ptr)
+	}
+
+	block := make([]byte, s.blksz)
+
+	if _, e := s.file.ReadAt(block, s.hdrsz+int64(ptr)*int64(s.blksz)); e != nil {
+		return nil, e
+	}
+
+	return block, nil
+}
+
+// leaf reassembles the chain starting at head and decodes the entries that
+// parse cleanly, and returns the blocks of the chain. The last result is false
+// if the chain or its contents ended early.
+func (s *salvager) leaf(head uint) (*leafNode, []uint, bool) {
+	node := &leafNode{self: head}
+	buf := []byte{}
+	seen := map[uint]bool{}
+	blocks := []uint{}
+	ok := true
+
+	for ptr := head; ptr != maxptr; {
+		if seen[ptr] {
+			ok = false
+			break
+		}
+		seen[ptr] = true
+		blocks = append(blocks, ptr)
+
+		block, e := s.block(ptr)
+		if e != nil || block[0] != LeafNode || block[1] != LeafNode {
+			ok = false
+			break
+		}
+
+		buf = append(buf, block[2:s.blksz-4]...)
+
+		ptr = uint(byteorder.BigEndian.Uint32(block[s.blksz-4:]))
+	}
+
+	if len(buf) < 4 {
+		return node, blocks, false
+	}
+
+	N := int(byteorder.BigEndian.Uint32(buf))
+	off := 4
+
+	for k := 0; k < N; k++ {
+		if len(buf)-off < s.keysz {
+			return node, blocks, false
+		}
+
+		key := make(Key, s.keysz)
+		off += copy(key, buf[off:])
+
+		// leaf entries are stored in ascending order
+		if k > 0 && bytes.Compare(node.keys[k-1], key) >= 0 {
+			return node, blocks, false
+		}
+
+		u, l, e := byteorder.BigEndian.UVarint(buf[off:])
+		if e != nil || u > uint64(len(buf)-off-l) {
+			return node, blocks, false
+		}
+		off += l
+
+		data := make(ByteArray, u)
+		off += copy(data, buf[off:])
+
+		node.keys = append(node.keys, key)
+		node.data = append(node.data, data)
+	}
+
+	// a real leaf uses exactly as many blocks as its contents need
+	if off <= len(buf)-(s.blksz-6) {
+		return node, blocks, false
+	}
+
+	return node, blocks, ok
+}
+
+type salvageChain struct {
+	node   *leafNode
+	blocks []uint
+	ok     bool
+	freed  bool
+}
+
+// Salvage recovers records from a damaged btreedb5 file without trusting its
+// index nodes or root descriptors. Every block of src is scanned, leaf
+// chains are reassembled by following their continuation pointers, and the
+// entries that decode cleanly are written into a new database at dst through
+// Insert. When several copies of a key survive, the newest one is kept, see
+// salvageRecord.newer.
+func Salvage(src, dst string, opts SalvageOptions) (r *SalvageReport, e error) {
+	s := &salvager{hdrsz: 512}
+
+	s.file, e = os.Open(src)
+	if e != nil {
+		return nil, errors.Wrapf(e, "failed to open the damaged file")
+	}
+	defer s.file.Close()
+
+	hdr := make([]byte, s.hdrsz)
+	if _, e := io.ReadFull(s.file, hdr); e != nil {
+		return nil, errors.Wrapf(e, "failed to read the header")
+	}
+
+	s.blksz = opts.BlockSize
+	if s.blksz == 0 {
+		s.blksz = int(byteorder.BigEndian.Int32(hdr[8:]))
+	}
+
+	s.keysz = opts.KeySize
+	if s.keysz == 0 {
+		s.keysz = int(byteorder.BigEndian.Int32(hdr[28:]))
+	}
+
+	ident := opts.Identifier
+	if ident == "" {
+		ident = string(bytes.TrimRight(hdr[12:28], "\x00"))
+	}
+
+	geometry := Options{
+		Identifier: ident,
+		BlockSize:  s.blksz,
+		KeySize:    s.keysz,
+		Overwrite:  opts.Overwrite,
+	}
+
+	if e := geometry.validate(); e != nil {
+		return nil, e
+	}
+
+	fileinfo, e := s.file.Stat()
+	if e != nil {
+		return nil, errors.Wrapf(e, "failed to stat")
+	}
+
+	s.blks = uint((fileinfo.Size() - s.hdrsz) / int64(s.blksz))
+	s.report.Blocks = s.blks
+
+	// first pass: collect leaf blocks and free blocks.
+	leaves := []uint{}
+	nexts := map[uint]uint{}
+	frees := map[uint]*salvageFree{}
+	fmax := freemax(s.blksz)
+
+	for ptr := uint(0); ptr < s.blks; ptr++ {
+		block, e := s.block(ptr)
+		if e != nil {
+			return nil, errors.Wrapf(e, "failed to read block %d", ptr)
+		}
+
+		switch {
+		case block[0] == LeafNode && block[1] == LeafNode:
+			leaves = append(leaves, ptr)
+			nexts[ptr] = uint(byteorder.BigEndian.Uint32(block[s.blksz-4:]))
+		case block[0] == FreeNode && block[1] == FreeNode:
+			N := int(byteorder.BigEndian.Uint32(block[6:]))
+			if N > fmax {
+				N = fmax
+			}
+
+			f := &salvageFree{next: uint(byteorder.BigEndian.Uint32(block[2:]))}
+			off := 10
+			for k := 0; k < N; k++ {
+				f.ptrs = append(f.ptrs, uint(byteorder.BigEndian.Uint32(block[off:])))
+				off += 4
+			}
+			frees[ptr] = f
+		}
+	}
+
+	// the free list of the current root, if the header still has it
+	hint := uint(byteorder.BigEndian.Uint32(hdr[33:]))
+	if byteorder.Byte2Bool(hdr[32]) {
+		hint = uint(byteorder.BigEndian.Uint32(hdr[50:]))
+	}
+
+	freed, known := freelist(frees, hint)
+	s.report.FreeList = known
+
+	// second pass: decode the chains. A leaf block is a head if no leaf block
+	// on the same side of the free list points to it. As stale blocks may
+	// still point anywhere, the other live blocks are tried as heads too,
+	// unless an intact chain goes through them.
+	pointed := map[uint]bool{}
+	for _, ptr := range leaves {
+		if next := nexts[ptr]; next != maxptr && freed[next] == freed[ptr] {
+			pointed[next] = true
+		}
+	}
+
+	chains := []*salvageChain{}
+	inner := map[uint]bool{}
+
+	decode := func(head uint) *salvageChain {
+		node, blocks, ok := s.leaf(head)
+
+		c := &salvageChain{node: node, blocks: blocks, ok: ok}
+		for _, ptr := range blocks {
+			c.freed = c.freed || freed[ptr]
+		}
+
+		if ok && !c.freed {
+			for _, ptr := range blocks[1:] {
+				inner[ptr] = true
+			}
+		}
+
+		return c
+	}
+
+	for _, head := range leaves {
+		if !pointed[head] {
+			chains = append(chains, decode(head))
+		}
+	}
+
+	extra := []*salvageChain{}
+	for _, head := range leaves {
+		if pointed[head] && !freed[head] && !inner[head] {
+			if c := decode(head); c.ok && !c.freed {
+				extra = append(extra, c)
+			}
+		}
+	}
+
+	for _, c := range extra {
+		if !inner[c.node.self] {
+			chains = append(chains, c)
+		}
+	}
+
+	// third pass: keep the newest copy of each key.
+	records := map[string]*salvageRecord{}
+
+	for _, c := range chains {
+		s.report.Leaves++
+
+		if !c.ok {
+			s.report.Broken++
+		}
+
+		if c.freed {
+			s.report.Freed++
+		}
+
+		if (!c.ok || c.freed) && !opts.Partial {
+			continue
+		}
+
+		for k := range c.node.keys {
+			rec := &salvageRecord{broken: !c.ok, freed: c.freed, data: c.node.data[k]}
+
+			old, dup := records[string(c.node.keys[k])]
+			if dup {
+				newer, ordered := rec.newer(old)
+				if !ordered {
+					s.report.Ambiguous++
+					continue
+				}
+
+				s.report.Duplicates++
+				if !newer {
+					continue
+				}
+			}
+
+			records[string(c.node.keys[k])] = rec
+		}
+	}
+
+	keys := make([]string, 0, len(records))
+	for k := range records {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h, e := Create(dst, geometry)
+	if e != nil {
+		return nil, e
+	}
+
+	for _, k := range keys {
+		if e := h.Insert(Key(k), records[k].data); e != nil {
+			h.Close()
+			return nil, errors.Wrapf(e, "failed to insert")
+		}
+
+		s.report.Records++
+	}
+
+	if e := h.Close(); e != nil {
+		return nil, errors.Wrapf(e, "failed to close the new database")
+	}
+
+	return &s.report, nil
+}
@@ -0,0 +1,166 @@
+package btreedb5
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// wipeIndex overwrites both root descriptors and zeroes every index block.
+func wipeIndex(t *testing.T, file string) {
+	f, e := os.OpenFile(file, os.O_RDWR, 0644)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer f.Close()
+
+	if _, e := f.WriteAt(bytes.Repeat([]byte{0xff}, 512-32), 32); e != nil {
+		t.Fatal(e)
+	}
+
+	st, _ := f.Stat()
+	block := make([]byte, 512)
+	for off := int64(512); off < st.Size(); off += 512 {
+		f.ReadAt(block, off)
+		if block[0] == IndexNode && block[1] == IndexNode {
+			f.WriteAt(make([]byte, 512), off)
+		}
+	}
+}
+
+func TestSalvage(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "damaged")
+	dst := filepath.Join(dir, "salvaged")
+
+	h, e := Create(src, Options{Identifier: "World4", BlockSize: 512, KeySize: 5})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	key := func(i int) Key {
+		return Key{1, byte(i >> 16), byte(i >> 8), byte(i), 0}
+	}
+
+	for i := 0; i < 2000; i++ {
+		if e := h.Insert(key(i), []byte(fmt.Sprint("old", i))); e != nil {
+			t.Fatal(e)
+		}
+	}
+	h.Commit()
+
+	for i := 0; i < 2000; i += 3 {
+		if e := h.Insert(key(i), []byte(fmt.Sprint("new", i))); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	if e := h.Close(); e != nil {
+		t.Fatal(e)
+	}
+
+	wipeIndex(t, src)
+
+	r, e := Salvage(src, dst, SalvageOptions{})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if r.Records != 2000 {
+		t.Fatalf("expect 2000 records, got %+v", r)
+	}
+
+	h, e = Load(dst)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer h.Close()
+
+	for i := 0; i < 2000; i++ {
+		data, e := h.Get(key(i))
+		if e != nil {
+			t.Fatal(i, e)
+		}
+
+		expect := fmt.Sprint("old", i)
+		if i%3 == 0 {
+			expect = fmt.Sprint("new", i)
+		}
+
+		if string(data) != expect {
+			t.Fatalf("record %d: expect %q, got %q", i, expect, data)
+		}
+	}
+}
+
+// TestSalvageReuse has leaves spanning several blocks, rewritten over many
+// commits, so that the free list hands low blocks out again and stale copies
+// of every key survive in freed blocks.
+func TestSalvageReuse(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "damaged")
+	dst := filepath.Join(dir, "salvaged")
+
+	h, e := Create(src, Options{Identifier: "World4", BlockSize: 512, KeySize: 5})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	r := rand.New(rand.NewSource(27))
+	want := map[string][]byte{}
+
+	for round := 0; round < 12; round++ {
+		for i := 0; i < 60; i++ {
+			if round != 0 && r.Intn(3) != 0 {
+				continue
+			}
+
+			key := Key{2, 0, 0, byte(i), 0}
+			data := bytes.Repeat([]byte{byte(round), byte(i)}, 1+r.Intn(600))
+			if e := h.Insert(key, data); e != nil {
+				t.Fatal(e)
+			}
+
+			want[string(key)] = data
+		}
+
+		if e := h.Commit(); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	if e := h.Close(); e != nil {
+		t.Fatal(e)
+	}
+
+	wipeIndex(t, src)
+
+	rep, e := Salvage(src, dst, SalvageOptions{})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if rep.Records != len(want) || rep.Ambiguous != 0 || !rep.FreeList {
+		t.Fatalf("expect %d records, got %+v", len(want), rep)
+	}
+
+	h, e = Load(dst)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer h.Close()
+
+	for k, v := range want {
+		data, e := h.Get(Key(k))
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		if !bytes.Equal(data, v) {
+			t.Fatalf("record %x: got the copy of round %d, expect round %d", k, data[0], v[0])
+		}
+	}
+}
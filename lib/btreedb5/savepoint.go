@@ -0,0 +1,127 @@
+package btreedb5
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrSavepoint = errors.New("savepoint not active")
+)
+
+// Savepoint marks a state inside the uncommitted transaction, which can be
+// restored by RollbackTo without losing the changes made before it.
+type Savepoint struct {
+	tree             BTree
+	blks             uint
+	held             int
+	used_uncommitted map[uint]bool
+	free_committed   map[uint]bool
+	free_uncommitted map[uint]bool
+}
+
+func copyset(m map[uint]bool) map[uint]bool {
+	r := make(map[uint]bool, len(m))
+	for k, v := range m {
+		r[k] = v
+	}
+	return r
+}
+
+// Savepoint records the current root and free lists. Savepoints nest, and
+// all of them are released by Commit or Rollback.
+func (h *BTreeDB5) Savepoint() *Savepoint {
+	h.freemu.Lock()
+	defer h.freemu.Unlock()
+
+	sp := &Savepoint{
+		tree:             h.Tree,
+		blks:             h.file.Cap(),
+		held:             len(h.held),
+		used_uncommitted: copyset(h.used_uncommitted),
+		free_committed:   copyset(h.free_committed),
+		free_uncommitted: copyset(h.free_uncommitted),
+	}
+
+	h.savepoints = append(h.savepoints, sp)
+
+	return sp
+}
+
+func (h *BTreeDB5) savepoint(sp *Savepoint) int {
+	for k := range h.savepoints {
+		if h.savepoints[k] == sp {
+			return k
+		}
+	}
+
+	return -1
+}
+
+// RollbackTo undoes every change made after sp. sp stays active, while the
+// savepoints created after it are released.
+func (h *BTreeDB5) RollbackTo(sp *Savepoint) (e error) {
+	defer func() {
+		k := recover()
+		if k != nil {
+			e = errors.Errorf("%+v\n", k)
+		}
+	}()
+
+	h.freemu.Lock()
+	defer h.freemu.Unlock()
+
+	i := h.savepoint(sp)
+	if i == -1 {
+		return ErrSavepoint
+	}
+
+	h.savepoints = h.savepoints[:i+1]
+
+	// blocks freed after sp are reachable from its root again
+	h.held = h.held[:sp.held]
+
+	h.Tree = sp.tree
+	h.used_uncommitted = copyset(sp.used_uncommitted)
+	h.free_committed = copyset(sp.free_committed)
+	h.free_uncommitted = copyset(sp.free_uncommitted)
+
+	if h.file.Cap() != sp.blks {
+		if e := h.file.Resize(sp.blks); e != nil {
+			return errors.Wrapf(e, "failed to resize the block file")
+		}
+	}
+
+	return nil
+}
+
+// Release forgets sp and the savepoints created after it, keeping their
+// changes. Once no savepoint is left, the blocks they kept from reuse are
+// freed.
+func (h *BTreeDB5) Release(sp *Savepoint) error {
+	h.freemu.Lock()
+	defer h.freemu.Unlock()
+
+	i := h.savepoint(sp)
+	if i == -1 {
+		return ErrSavepoint
+	}
+
+	h.savepoints = h.savepoints[:i]
+
+	if len(h.savepoints) == 0 {
+		h.freelist_release()
+	}
+
+	return nil
+}
+
+// freelist_release frees the blocks held for savepoints. The caller must
+// hold freemu, or make sure nothing else touches the free lists.
+func (h *BTreeDB5) freelist_release() {
+	for _, ptr := range h.held {
+		h.freelist_free(ptr)
+	}
+
+	h.savepoints = nil
+	h.held = nil
+}
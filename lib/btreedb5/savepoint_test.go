@@ -0,0 +1,97 @@
+package btreedb5
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestSavepoint(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "db")
+
+	h, e := Create(file, Options{Identifier: "World4", BlockSize: 512, KeySize: 5})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	key := func(i int) Key {
+		return Key{1, byte(i >> 16), byte(i >> 8), byte(i), 0}
+	}
+
+	insert := func(from, to int, prefix string) {
+		for i := from; i < to; i++ {
+			if e := h.Insert(key(i), []byte(fmt.Sprint(prefix, i))); e != nil {
+				t.Fatal(e)
+			}
+		}
+	}
+
+	expect := func(from, to int, prefix string) {
+		for i := from; i < to; i++ {
+			data, e := h.Get(key(i))
+			if prefix == "" {
+				if e == nil {
+					t.Fatalf("record %d: expect nothing, got %q", i, data)
+				}
+				continue
+			}
+
+			if e != nil || string(data) != fmt.Sprint(prefix, i) {
+				t.Fatalf("record %d: expect %q, got %q, %v", i, fmt.Sprint(prefix, i), data, e)
+			}
+		}
+	}
+
+	insert(0, 500, "a")
+	if e := h.Commit(); e != nil {
+		t.Fatal(e)
+	}
+
+	insert(500, 1000, "a")
+
+	sp1 := h.Savepoint()
+	insert(1000, 1500, "b")
+	insert(0, 1000, "b")
+
+	sp2 := h.Savepoint()
+	insert(1500, 2000, "c")
+	insert(0, 1500, "c")
+
+	if e := h.RollbackTo(sp2); e != nil {
+		t.Fatal(e)
+	}
+	expect(0, 1500, "b")
+	expect(1500, 2000, "")
+
+	insert(0, 100, "d")
+
+	if e := h.RollbackTo(sp1); e != nil {
+		t.Fatal(e)
+	}
+	expect(0, 1000, "a")
+	expect(1000, 2000, "")
+
+	if e := h.RollbackTo(sp2); e != ErrSavepoint {
+		t.Fatalf("expect sp2 to be released, got %v", e)
+	}
+
+	insert(1000, 1200, "e")
+
+	if e := h.Release(sp1); e != nil {
+		t.Fatal(e)
+	}
+
+	if e := h.Close(); e != nil {
+		t.Fatal(e)
+	}
+
+	h, e = Load(file)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer h.Close()
+
+	expect(0, 1000, "a")
+	expect(1000, 1200, "e")
+	expect(1200, 2000, "")
+}
@@ -16,12 +16,12 @@ func (this *ByteArray) Read(rd io.Reader, endian byteorder.ByteOrder) error {
 		return e
 	}
 
-	*this = make([]byte, u)
-
-	if _, e := io.ReadFull(rd, *this); e != nil {
+	b, e := ReadLength(rd, uint64(u))
+	if e != nil {
 		return e
 	}
 
+	*this = b
 	return nil
 }
 
@@ -33,6 +33,10 @@ func (this *ByteArray) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, err
 		return 0, e
 	}
 
+	if uint64(u) > uint64(len(buf)-l) {
+		return l, errShortRead
+	}
+
 	*this = make([]byte, u)
 
 	copy(*this, buf[l:])
@@ -0,0 +1,188 @@
+package data_types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xhebox/bstruct/byteorder"
+)
+
+// CelestialCoordinate locates a system of the universe, and optionally a
+// planet of it and a satellite of that planet. Planets and satellites are
+// numbered from 1, 0 means none. A zero location is the null coordinate.
+//
+// On the wire, it is the location then the planet and the satellite, all
+// int32. As a string, it is "x:y:z:planet:satellite", without the trailing
+// zero parts, or "null".
+type CelestialCoordinate struct {
+	Location  Vec3I
+	Planet    int32
+	Satellite int32
+}
+
+func (this CelestialCoordinate) IsNull() bool {
+	return this.Location == Vec3I{}
+}
+
+func (this CelestialCoordinate) IsSystem() bool {
+	return !this.IsNull() && this.Planet == 0
+}
+
+func (this CelestialCoordinate) IsPlanetaryBody() bool {
+	return !this.IsNull() && this.Planet != 0 && this.Satellite == 0
+}
+
+func (this CelestialCoordinate) IsSatellite() bool {
+	return !this.IsNull() && this.Planet != 0 && this.Satellite != 0
+}
+
+func (this CelestialCoordinate) String() string {
+	if this.IsNull() {
+		return "null"
+	}
+
+	r := fmt.Sprintf("%d:%d:%d", this.Location[0], this.Location[1], this.Location[2])
+	if this.Planet != 0 {
+		r += ":" + strconv.Itoa(int(this.Planet))
+		if this.Satellite != 0 {
+			r += ":" + strconv.Itoa(int(this.Satellite))
+		}
+	}
+
+	return r
+}
+
+// Filename is the name of the file of the world in the universe directory,
+// e.g. "x_y_z_planet_satellite.world".
+func (this CelestialCoordinate) Filename() string {
+	return strings.Replace(this.String(), ":", "_", -1) + ".world"
+}
+
+// ParseCelestialCoordinate reads what String or Filename return. Like the
+// game, parts may be separated by ':', '_' or spaces.
+func ParseCelestialCoordinate(s string) (CelestialCoordinate, error) {
+	r := CelestialCoordinate{}
+
+	s = strings.TrimSuffix(s, ".world")
+	if s == "" || s == "null" {
+		return r, nil
+	}
+
+	parts := strings.FieldsFunc(s, func(c rune) bool {
+		return c == ':' || c == '_' || c == ' '
+	})
+	if len(parts) < 3 || len(parts) > 5 {
+		return r, fmt.Errorf("invalid celestial coordinate %q", s)
+	}
+
+	for k := range parts {
+		n, e := strconv.ParseInt(parts[k], 10, 32)
+		if e != nil {
+			return r, fmt.Errorf("invalid celestial coordinate %q", s)
+		}
+
+		switch k {
+		case 3:
+			r.Planet = int32(n)
+		case 4:
+			r.Satellite = int32(n)
+		default:
+			r.Location[k] = int32(n)
+		}
+	}
+
+	return r, nil
+}
+
+func (this *CelestialCoordinate) Read(rd io.Reader, endian byteorder.ByteOrder) error {
+	return readFixed(rd, endian, 20, this)
+}
+
+func (this *CelestialCoordinate) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 20 {
+		return 0, errShortRead
+	}
+
+	if _, e := this.Location.ReadBuf(buf, endian); e != nil {
+		return 0, e
+	}
+
+	this.Planet = endian.Int32(buf[12:])
+	this.Satellite = endian.Int32(buf[16:])
+	return 20, nil
+}
+
+func (this *CelestialCoordinate) Write(wt io.Writer, endian byteorder.ByteOrder) error {
+	return writeFixed(wt, endian, 20, this)
+}
+
+func (this *CelestialCoordinate) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 20 {
+		return 0, errShortWrite
+	}
+
+	if _, e := this.Location.WriteBuf(buf, endian); e != nil {
+		return 0, e
+	}
+
+	endian.PutInt32(buf[12:], this.Planet)
+	endian.PutInt32(buf[16:], this.Satellite)
+	return 20, nil
+}
+
+// celestialJSON is the json object of the game.
+type celestialJSON struct {
+	Location  [3]int32 `json:"location"`
+	Planet    int32    `json:"planet"`
+	Satellite int32    `json:"satellite"`
+}
+
+// MarshalJSON writes null for the null coordinate, or an object like the
+// game.
+func (this CelestialCoordinate) MarshalJSON() ([]byte, error) {
+	if this.IsNull() {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(celestialJSON{
+		Location:  this.Location,
+		Planet:    this.Planet,
+		Satellite: this.Satellite,
+	})
+}
+
+// UnmarshalJSON reads null, an object or a string, as the game does.
+func (this *CelestialCoordinate) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		*this = CelestialCoordinate{}
+		return nil
+	case len(data) != 0 && data[0] == '"':
+		var s string
+		if e := json.Unmarshal(data, &s); e != nil {
+			return e
+		}
+
+		r, e := ParseCelestialCoordinate(s)
+		if e != nil {
+			return e
+		}
+
+		*this = r
+		return nil
+	}
+
+	v := celestialJSON{}
+	if e := json.Unmarshal(data, &v); e != nil {
+		return e
+	}
+
+	*this = CelestialCoordinate{Location: v.Location, Planet: v.Planet, Satellite: v.Satellite}
+	return nil
+}
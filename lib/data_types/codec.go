@@ -0,0 +1,658 @@
+package data_types
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/xhebox/bstruct/byteorder"
+)
+
+// Encode writes v, usually a pointer to a struct, field by field in order. A
+// field whose pointer implements Writer writes itself, otherwise it is written
+// by its kind, or as its `sb` tag says:
+//
+//	varint, uvarint    a signed or unsigned varint
+//	u8, u16, u32, u64  an unsigned integer of that size
+//	i8, i16, i32, i64  a signed integer of that size
+//	f32, f64           a float of that size
+//	bool               a byte, 1 for true
+//	bytes              a count, then the bytes of a string or []byte
+//	maybe              a bool, then the value of a non nil pointer
+//	list               a count, then the elements of a slice
+//	-                  the field is skipped
+//
+// Tags are separated by commas, each one applying to the elements of the
+// one before, e.g. `sb:"maybe,list,varint"` for a *[]int64.
+//
+// Untagged, fixed size integers, floats and bools are written as their size
+// says, strings and []byte as bytes, slices as lists, arrays element by
+// element without a count, pointers as what they point to, and maps as a
+// count followed by keys and values, sorted by the bytes of the keys. int and
+// uint need a tag, and unexported fields are skipped.
+func Encode(wt io.Writer, endian byteorder.ByteOrder, v interface{}) error {
+	return encode(wt, endian, reflect.ValueOf(v), nil)
+}
+
+// Decode reads what Encode writes into v, which must be a pointer. See
+// LimitLength for input that can not be trusted.
+func Decode(rd io.Reader, endian byteorder.ByteOrder, v interface{}) error {
+	r := reflect.ValueOf(v)
+	if r.Kind() != reflect.Ptr || r.IsNil() {
+		return fmt.Errorf("decode needs a non nil pointer, got %T", v)
+	}
+
+	return decode(rd, endian, r.Elem(), nil)
+}
+
+// EncodedSize returns the number of bytes Encode writes for v, so that a
+// buffer for EncodeBuf can be allocated up front.
+func EncodedSize(v interface{}) (int, error) {
+	c := &countWriter{}
+
+	// the byte order does not change sizes
+	if e := Encode(c, byteorder.BigEndian, v); e != nil {
+		return 0, e
+	}
+
+	return c.n, nil
+}
+
+// EncodeBuf is Encode to a buffer, which must hold EncodedSize(v) bytes.
+func EncodeBuf(buf []byte, endian byteorder.ByteOrder, v interface{}) (int, error) {
+	w := &sliceWriter{buf: buf}
+	e := Encode(w, endian, v)
+	return w.n, e
+}
+
+// DecodeBuf is Decode from a buffer, and returns the number of bytes read. No
+// length can exceed the buffer.
+func DecodeBuf(buf []byte, endian byteorder.ByteOrder, v interface{}) (int, error) {
+	rd := bytes.NewReader(buf)
+	e := Decode(LimitLength(rd, uint64(len(buf))), endian, v)
+	return len(buf) - rd.Len(), e
+}
+
+// EncodeBytes returns the bytes of v, in a buffer of exactly its size.
+func EncodeBytes(endian byteorder.ByteOrder, v interface{}) ([]byte, error) {
+	n, e := EncodedSize(v)
+	if e != nil {
+		return nil, e
+	}
+
+	buf := make([]byte, n)
+	if _, e := EncodeBuf(buf, endian, v); e != nil {
+		return nil, e
+	}
+
+	return buf, nil
+}
+
+type countWriter struct {
+	n int
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	c.n += len(p)
+	return len(p), nil
+}
+
+type sliceWriter struct {
+	buf []byte
+	n   int
+}
+
+func (s *sliceWriter) Write(p []byte) (int, error) {
+	if len(p) > len(s.buf)-s.n {
+		return 0, errShortWrite
+	}
+
+	s.n += copy(s.buf[s.n:], p)
+	return len(p), nil
+}
+
+type field struct {
+	index int
+	name  string
+	tags  []string
+}
+
+// fields caches the encoded fields of struct types.
+var fields sync.Map
+
+func fieldsOf(t reflect.Type) []field {
+	if r, ok := fields.Load(t); ok {
+		return r.([]field)
+	}
+
+	r := []field{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		tag := f.Tag.Get("sb")
+		if tag == "-" {
+			continue
+		}
+
+		var tags []string
+		if tag != "" {
+			tags = strings.Split(tag, ",")
+		}
+
+		r = append(r, field{index: i, name: f.Name, tags: tags})
+	}
+
+	fields.Store(t, r)
+	return r
+}
+
+var (
+	readerType = reflect.TypeOf((*Reader)(nil)).Elem()
+	writerType = reflect.TypeOf((*Writer)(nil)).Elem()
+)
+
+// addr returns a pointer to v, to a copy if v is not addressable.
+func addr(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v.Addr()
+	}
+
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p
+}
+
+func integer(v reflect.Value) (int64, uint64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		return i, uint64(i), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		return int64(u), u, true
+	}
+
+	return 0, 0, false
+}
+
+func isInteger(k reflect.Kind) bool {
+	return (k >= reflect.Int && k <= reflect.Int64) || (k >= reflect.Uint && k <= reflect.Uintptr)
+}
+
+func isSigned(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+// fits tells if an integer, i as signed and u as unsigned, fits in size bytes.
+func fits(signedTag bool, size int, i int64, u uint64, signed bool) bool {
+	bits := uint(size) * 8
+
+	if !signedTag {
+		return !(signed && i < 0) && (bits == 64 || u>>bits == 0)
+	}
+
+	if !signed && u > math.MaxInt64 {
+		return false
+	}
+
+	return bits == 64 || (i >= -1<<(bits-1) && i < 1<<(bits-1))
+}
+
+func setInteger(v reflect.Value, i int64, u uint64, signed bool) error {
+	if isSigned(v.Kind()) {
+		if !signed && u > math.MaxInt64 {
+			return fmt.Errorf("%d overflows %s", u, v.Type())
+		}
+		if !signed {
+			i = int64(u)
+		}
+
+		if v.OverflowInt(i) {
+			return fmt.Errorf("%d overflows %s", i, v.Type())
+		}
+
+		v.SetInt(i)
+		return nil
+	}
+
+	if signed {
+		if i < 0 {
+			return fmt.Errorf("%d overflows %s", i, v.Type())
+		}
+		u = uint64(i)
+	}
+
+	if v.OverflowUint(u) {
+		return fmt.Errorf("%d overflows %s", u, v.Type())
+	}
+
+	v.SetUint(u)
+	return nil
+}
+
+// defaultTag is the tag of a value of kind k without one, or "" if it is
+// handled by its kind.
+func defaultTag(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int8:
+		return "i8"
+	case reflect.Int16:
+		return "i16"
+	case reflect.Int32:
+		return "i32"
+	case reflect.Int64:
+		return "i64"
+	case reflect.Uint8:
+		return "u8"
+	case reflect.Uint16:
+		return "u16"
+	case reflect.Uint32:
+		return "u32"
+	case reflect.Uint64:
+		return "u64"
+	case reflect.Float32:
+		return "f32"
+	case reflect.Float64:
+		return "f64"
+	case reflect.String:
+		return "bytes"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "bytes"
+		}
+		return "list"
+	}
+
+	return ""
+}
+
+var fixedSizes = map[string]int{
+	"u8": 1, "u16": 2, "u32": 4, "u64": 8,
+	"i8": 1, "i16": 2, "i32": 4, "i64": 8,
+}
+
+func encode(wt io.Writer, endian byteorder.ByteOrder, v reflect.Value, tags []string) error {
+	if !v.IsValid() {
+		return fmt.Errorf("can not encode nil")
+	}
+
+	var tag string
+	if len(tags) != 0 {
+		tag, tags = tags[0], tags[1:]
+	} else {
+		if reflect.PtrTo(v.Type()).Implements(writerType) {
+			return addr(v).Interface().(Writer).Write(wt, endian)
+		}
+
+		tag = defaultTag(v.Type())
+	}
+
+	if size, ok := fixedSizes[tag]; ok {
+		i, u, ok := integer(v)
+		if !ok {
+			return fmt.Errorf("%s needs an integer, got %s", tag, v.Type())
+		}
+
+		if !fits(tag[0] == 'i', size, i, u, isSigned(v.Kind())) {
+			return fmt.Errorf("%v overflows %s", v, tag)
+		}
+
+		buf := make([]byte, 8)
+		switch size {
+		case 1:
+			buf[0] = byte(u)
+		case 2:
+			endian.PutUint16(buf, uint16(u))
+		case 4:
+			endian.PutUint32(buf, uint32(u))
+		case 8:
+			endian.PutUint64(buf, u)
+		}
+
+		_, e := wt.Write(buf[:size])
+		return e
+	}
+
+	switch tag {
+	case "varint":
+		if !isSigned(v.Kind()) {
+			return fmt.Errorf("varint needs a signed integer, got %s", v.Type())
+		}
+
+		return byteorder.PutVarint(wt, endian, v.Int())
+	case "uvarint":
+		if !isInteger(v.Kind()) || isSigned(v.Kind()) {
+			return fmt.Errorf("uvarint needs an unsigned integer, got %s", v.Type())
+		}
+
+		return byteorder.PutUVarint(wt, endian, v.Uint())
+	case "f32", "f64":
+		if v.Kind() != reflect.Float32 && v.Kind() != reflect.Float64 {
+			return fmt.Errorf("%s needs a float, got %s", tag, v.Type())
+		}
+
+		if tag == "f32" {
+			return byteorder.PutFloat32(wt, endian, float32(v.Float()))
+		}
+		return byteorder.PutFloat64(wt, endian, v.Float())
+	case "bool":
+		if v.Kind() != reflect.Bool {
+			return fmt.Errorf("bool needs a bool, got %s", v.Type())
+		}
+
+		return byteorder.PutBool(wt, v.Bool())
+	case "bytes":
+		var b ByteArray
+		switch {
+		case v.Kind() == reflect.String:
+			b = ByteArray(v.String())
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			b = ByteArray(v.Bytes())
+		default:
+			return fmt.Errorf("bytes needs a string or []byte, got %s", v.Type())
+		}
+
+		return b.Write(wt, endian)
+	case "maybe":
+		if v.Kind() != reflect.Ptr {
+			return fmt.Errorf("maybe needs a pointer, got %s", v.Type())
+		}
+
+		if e := byteorder.PutBool(wt, !v.IsNil()); e != nil || v.IsNil() {
+			return e
+		}
+
+		return encode(wt, endian, v.Elem(), tags)
+	case "list":
+		if v.Kind() != reflect.Slice {
+			return fmt.Errorf("list needs a slice, got %s", v.Type())
+		}
+
+		if e := byteorder.PutUVarint(wt, endian, uint64(v.Len())); e != nil {
+			return e
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			if e := encode(wt, endian, v.Index(i), tags); e != nil {
+				return fmt.Errorf("[%d]: %w", i, e)
+			}
+		}
+
+		return nil
+	case "":
+	default:
+		return fmt.Errorf("unknown tag %s", tag)
+	}
+
+	switch v.Kind() {
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if e := encode(wt, endian, v.Index(i), tags); e != nil {
+				return fmt.Errorf("[%d]: %w", i, e)
+			}
+		}
+	case reflect.Struct:
+		for _, f := range fieldsOf(v.Type()) {
+			if e := encode(wt, endian, v.Field(f.index), f.tags); e != nil {
+				return fmt.Errorf("%s: %w", f.name, e)
+			}
+		}
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return fmt.Errorf("can not encode nil %s", v.Type())
+		}
+
+		return encode(wt, endian, v.Elem(), tags)
+	case reflect.Map:
+		if e := byteorder.PutUVarint(wt, endian, uint64(v.Len())); e != nil {
+			return e
+		}
+
+		// keys are sorted by their bytes, as Map does
+		keys := v.MapKeys()
+		enc := make([][]byte, len(keys))
+		for i, k := range keys {
+			buf := &bytes.Buffer{}
+			if e := encode(buf, endian, k, nil); e != nil {
+				return e
+			}
+			enc[i] = buf.Bytes()
+		}
+
+		sort.Sort(byEncoding[reflect.Value]{keys, enc})
+
+		for i, k := range keys {
+			if _, e := wt.Write(enc[i]); e != nil {
+				return e
+			}
+
+			if e := encode(wt, endian, v.MapIndex(k), nil); e != nil {
+				return fmt.Errorf("[%v]: %w", k, e)
+			}
+		}
+	default:
+		return fmt.Errorf("can not encode %s without a tag", v.Type())
+	}
+
+	return nil
+}
+
+func decode(rd io.Reader, endian byteorder.ByteOrder, v reflect.Value, tags []string) error {
+	var tag string
+	if len(tags) != 0 {
+		tag, tags = tags[0], tags[1:]
+	} else {
+		if reflect.PtrTo(v.Type()).Implements(readerType) {
+			return v.Addr().Interface().(Reader).Read(rd, endian)
+		}
+
+		tag = defaultTag(v.Type())
+	}
+
+	if size, ok := fixedSizes[tag]; ok {
+		if !isInteger(v.Kind()) {
+			return fmt.Errorf("%s needs an integer, got %s", tag, v.Type())
+		}
+
+		buf := make([]byte, size)
+		if _, e := io.ReadFull(rd, buf); e != nil {
+			return e
+		}
+
+		var u uint64
+		switch size {
+		case 1:
+			u = uint64(buf[0])
+		case 2:
+			u = uint64(endian.Uint16(buf))
+		case 4:
+			u = uint64(endian.Uint32(buf))
+		case 8:
+			u = endian.Uint64(buf)
+		}
+
+		if tag[0] == 'u' {
+			return setInteger(v, 0, u, false)
+		}
+
+		// sign extend
+		shift := uint(64 - size*8)
+		return setInteger(v, int64(u<<shift)>>shift, 0, true)
+	}
+
+	switch tag {
+	case "varint":
+		if !isSigned(v.Kind()) {
+			return fmt.Errorf("varint needs a signed integer, got %s", v.Type())
+		}
+
+		i, e := byteorder.Varint(rd, endian)
+		if e != nil {
+			return e
+		}
+
+		return setInteger(v, i, 0, true)
+	case "uvarint":
+		if !isInteger(v.Kind()) || isSigned(v.Kind()) {
+			return fmt.Errorf("uvarint needs an unsigned integer, got %s", v.Type())
+		}
+
+		u, e := byteorder.UVarint(rd, endian)
+		if e != nil {
+			return e
+		}
+
+		return setInteger(v, 0, u, false)
+	case "f32", "f64":
+		if v.Kind() != reflect.Float32 && v.Kind() != reflect.Float64 {
+			return fmt.Errorf("%s needs a float, got %s", tag, v.Type())
+		}
+
+		if tag == "f32" {
+			f, e := byteorder.Float32(rd, endian)
+			v.SetFloat(float64(f))
+			return e
+		}
+
+		f, e := byteorder.Float64(rd, endian)
+		v.SetFloat(f)
+		return e
+	case "bool":
+		if v.Kind() != reflect.Bool {
+			return fmt.Errorf("bool needs a bool, got %s", v.Type())
+		}
+
+		b, e := byteorder.Bool(rd)
+		v.SetBool(b)
+		return e
+	case "bytes":
+		var b ByteArray
+		switch {
+		case v.Kind() == reflect.String:
+			if e := b.Read(rd, endian); e != nil {
+				return e
+			}
+			v.SetString(string(b))
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			if e := b.Read(rd, endian); e != nil {
+				return e
+			}
+			v.SetBytes(b)
+		default:
+			return fmt.Errorf("bytes needs a string or []byte, got %s", v.Type())
+		}
+
+		return nil
+	case "maybe":
+		if v.Kind() != reflect.Ptr {
+			return fmt.Errorf("maybe needs a pointer, got %s", v.Type())
+		}
+
+		b, e := byteorder.Bool(rd)
+		if e != nil {
+			return e
+		}
+
+		if !b {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+
+		p := reflect.New(v.Type().Elem())
+		if e := decode(rd, endian, p.Elem(), tags); e != nil {
+			return e
+		}
+
+		v.Set(p)
+		return nil
+	case "list":
+		if v.Kind() != reflect.Slice {
+			return fmt.Errorf("list needs a slice, got %s", v.Type())
+		}
+
+		n, e := byteorder.UVarint(rd, endian)
+		if e != nil {
+			return e
+		}
+
+		if e := CheckLength(rd, n); e != nil {
+			return e
+		}
+
+		// the count is not trusted to preallocate
+		r := reflect.MakeSlice(v.Type(), 0, 0)
+		for i := uint64(0); i < n; i++ {
+			r = reflect.Append(r, reflect.Zero(v.Type().Elem()))
+			if e := decode(rd, endian, r.Index(int(i)), tags); e != nil {
+				return fmt.Errorf("[%d]: %w", i, e)
+			}
+		}
+
+		v.Set(r)
+		return nil
+	case "":
+	default:
+		return fmt.Errorf("unknown tag %s", tag)
+	}
+
+	switch v.Kind() {
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if e := decode(rd, endian, v.Index(i), tags); e != nil {
+				return fmt.Errorf("[%d]: %w", i, e)
+			}
+		}
+	case reflect.Struct:
+		for _, f := range fieldsOf(v.Type()) {
+			if e := decode(rd, endian, v.Field(f.index), f.tags); e != nil {
+				return fmt.Errorf("%s: %w", f.name, e)
+			}
+		}
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		return decode(rd, endian, v.Elem(), tags)
+	case reflect.Map:
+		n, e := byteorder.UVarint(rd, endian)
+		if e != nil {
+			return e
+		}
+
+		if e := CheckLength(rd, n); e != nil {
+			return e
+		}
+
+		r := reflect.MakeMap(v.Type())
+		for i := uint64(0); i < n; i++ {
+			k := reflect.New(v.Type().Key()).Elem()
+			if e := decode(rd, endian, k, nil); e != nil {
+				return e
+			}
+
+			m := reflect.New(v.Type().Elem()).Elem()
+			if e := decode(rd, endian, m, nil); e != nil {
+				return fmt.Errorf("[%v]: %w", k, e)
+			}
+
+			r.SetMapIndex(k, m)
+		}
+
+		v.Set(r)
+	default:
+		return fmt.Errorf("can not decode %s without a tag", v.Type())
+	}
+
+	return nil
+}
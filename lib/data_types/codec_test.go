@@ -0,0 +1,153 @@
+package data_types
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xhebox/bstruct/byteorder"
+)
+
+type codecInner struct {
+	Pos  Vec2I
+	Tags []string
+}
+
+type codecPacket struct {
+	Version  uint32
+	Id       int     `sb:"varint"`
+	Kind     int     `sb:"u8"`
+	Delta    int64   `sb:"i16"`
+	Scale    float64 `sb:"f32"`
+	Name     String
+	Raw      []byte
+	Note     *string  `sb:"maybe"`
+	None     *Vec2I   `sb:"maybe"`
+	Ids      []uint64 `sb:"list,uvarint"`
+	Nested   *[]int   `sb:"maybe,list,i8"`
+	Inner    codecInner
+	Pair     [2]bool
+	Names    map[string]uint8
+	Skip     int `sb:"-"`
+	internal int
+}
+
+func TestCodec(t *testing.T) {
+	note := "n"
+	nested := []int{-1, 2}
+	v := codecPacket{
+		Version: 747,
+		Id:      -2,
+		Kind:    200,
+		Delta:   -300,
+		Scale:   0.5,
+		Name:    "sb",
+		Raw:     []byte{9},
+		Note:    &note,
+		Ids:     []uint64{1, 300},
+		Nested:  &nested,
+		Inner:   codecInner{Pos: Vec2I{1, -1}, Tags: []string{"a"}},
+		Pair:    [2]bool{true, false},
+		Names:   map[string]uint8{"y": 1, "x": 7, "ab": 2},
+		Skip:    5,
+	}
+
+	want := []byte{
+		0, 0, 2, 235, // Version
+		3,          // Id
+		200,        // Kind
+		0xfe, 0xd4, // Delta
+		0x3f, 0, 0, 0, // Scale
+		2, 's', 'b', // Name
+		1, 9, // Raw
+		1, 1, 'n', // Note
+		0,                // None
+		2, 1, 0x82, 0x2c, // Ids
+		1, 2, 0xff, 2, // Nested
+		0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff, 1, 1, 'a', // Inner
+		1, 0, // Pair
+		3, 1, 'x', 7, 1, 'y', 1, 2, 'a', 'b', 2, // Names, sorted by key bytes
+	}
+
+	n, e := EncodedSize(&v)
+	if e != nil || n != len(want) {
+		t.Fatalf("size %d %v, want %d", n, e, len(want))
+	}
+
+	buf, e := EncodeBytes(byteorder.BigEndian, &v)
+	if e != nil || !bytes.Equal(buf, want) {
+		t.Fatalf("encoded % x %v\nwant    % x", buf, e, want)
+	}
+
+	w := &bytes.Buffer{}
+	if e := Encode(w, byteorder.BigEndian, v); e != nil || !bytes.Equal(w.Bytes(), want) {
+		t.Fatalf("encoded by value % x %v", w.Bytes(), e)
+	}
+
+	var r codecPacket
+	if e := Decode(bytes.NewReader(want), byteorder.BigEndian, &r); e != nil {
+		t.Fatal(e)
+	}
+
+	v.Skip = 0
+	if !reflect.DeepEqual(r, v) {
+		t.Fatalf("decoded %+v\nwant    %+v", r, v)
+	}
+
+	var rb codecPacket
+	if n, e := DecodeBuf(want, byteorder.BigEndian, &rb); e != nil || n != len(want) || !reflect.DeepEqual(rb, v) {
+		t.Fatalf("decodebuf %d %v", n, e)
+	}
+
+	if _, e := DecodeBuf(want[:len(want)-1], byteorder.BigEndian, &rb); e == nil {
+		t.Fatal("truncated input accepted")
+	}
+
+	if _, e := EncodeBuf(make([]byte, len(want)-1), byteorder.BigEndian, &v); e == nil {
+		t.Fatal("short buffer accepted")
+	}
+}
+
+func TestCodecErrors(t *testing.T) {
+	for _, c := range []struct {
+		v    interface{}
+		want string
+	}{
+		{&struct{ N int }{}, "N: can not encode int without a tag"},
+		{&struct {
+			N uint8 `sb:"varint"`
+		}{}, "N: varint needs a signed integer"},
+		{&struct {
+			N int `sb:"u8"`
+		}{N: 256}, "N: 256 overflows u8"},
+		{&struct {
+			N int `sb:"u16"`
+		}{N: -1}, "N: -1 overflows u16"},
+		{&struct {
+			N int `sb:"i8"`
+		}{N: 128}, "N: 128 overflows i8"},
+		{&struct {
+			L []int8 `sb:"list,bool"`
+		}{L: []int8{1}}, "L: [0]: bool needs a bool"},
+		{&struct {
+			N int `sb:"what"`
+		}{}, "N: unknown tag what"},
+	} {
+		_, e := EncodedSize(c.v)
+		if e == nil || !strings.HasPrefix(e.Error(), c.want) {
+			t.Errorf("%+v: got %v, want %s", c.v, e, c.want)
+		}
+	}
+
+	var small struct {
+		N uint8 `sb:"uvarint"`
+	}
+	if e := Decode(bytes.NewReader([]byte{0x82, 0x2c}), byteorder.BigEndian, &small); e == nil || !strings.Contains(e.Error(), "overflows") {
+		t.Fatalf("got %v", e)
+	}
+
+	if e := Decode(bytes.NewReader(nil), byteorder.BigEndian, small); e == nil {
+		t.Fatal("decoded into a value")
+	}
+}
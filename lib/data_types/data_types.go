@@ -1,6 +1,7 @@
 package data_types
 
 import (
+	"errors"
 	"io"
 
 	"github.com/xhebox/bstruct/byteorder"
@@ -21,3 +22,23 @@ type Writer interface {
 type BufWriter interface {
 	WriteBuf([]byte, byteorder.ByteOrder) (int, error)
 }
+
+// Serializer is implemented by every type of this package, through a pointer.
+type Serializer interface {
+	Reader
+	BufReader
+	Writer
+	BufWriter
+}
+
+// SerializerPtr lets a generic type use T by value: P is *T, which must be a
+// Serializer, e.g. List[String, *String].
+type SerializerPtr[T any] interface {
+	*T
+	Serializer
+}
+
+var (
+	errShortRead  = errors.New("not enough bytes to read")
+	errShortWrite = errors.New("not enough bytes to write")
+)
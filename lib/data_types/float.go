@@ -0,0 +1,36 @@
+package data_types
+
+import (
+	"io"
+
+	"github.com/xhebox/bstruct/byteorder"
+)
+
+// Float32 is a float32, e.g. for Maybe[Float32, *Float32].
+type Float32 float32
+
+func (this *Float32) Read(rd io.Reader, endian byteorder.ByteOrder) error {
+	return readFixed(rd, endian, 4, this)
+}
+
+func (this *Float32) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 4 {
+		return 0, errShortRead
+	}
+
+	*this = Float32(endian.Float32(buf))
+	return 4, nil
+}
+
+func (this *Float32) Write(wt io.Writer, endian byteorder.ByteOrder) error {
+	return writeFixed(wt, endian, 4, this)
+}
+
+func (this *Float32) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 4 {
+		return 0, errShortWrite
+	}
+
+	endian.PutFloat32(buf, float32(*this))
+	return 4, nil
+}
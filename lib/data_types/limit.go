@@ -0,0 +1,93 @@
+package data_types
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ErrTooLarge is returned when a length prefix, of bytes or elements, claims
+// more than the limit of the reader.
+type ErrTooLarge struct {
+	Length uint64
+	Max    uint64
+}
+
+func (e *ErrTooLarge) Error() string {
+	return fmt.Sprintf("length %d exceeds the limit %d", e.Length, e.Max)
+}
+
+// LengthLimiter is a reader that bounds the length prefixes read from it.
+// Readers that wrap another one implement it to pass the limit along.
+type LengthLimiter interface {
+	MaxLength() uint64
+}
+
+type limitedReader struct {
+	io.Reader
+	max uint64
+}
+
+func (l *limitedReader) MaxLength() uint64 {
+	return l.max
+}
+
+// LimitLength returns rd with every length prefix read from it bounded by
+// max, for input that can not be trusted. It applies to byte arrays and
+// strings, and to the counts of lists, maps and sets.
+func LimitLength(rd io.Reader, max uint64) io.Reader {
+	return &limitedReader{Reader: rd, max: max}
+}
+
+// MaxLength returns the limit of rd, or 0 if it has none.
+func MaxLength(rd io.Reader) uint64 {
+	if l, ok := rd.(LengthLimiter); ok {
+		return l.MaxLength()
+	}
+
+	return 0
+}
+
+// CheckLength returns an *ErrTooLarge if n exceeds the limit of rd.
+func CheckLength(rd io.Reader, n uint64) error {
+	if max := MaxLength(rd); max != 0 && n > max {
+		return &ErrTooLarge{Length: n, Max: max}
+	}
+
+	return nil
+}
+
+// chunkSize bounds what ReadLength allocates ahead of the data.
+const chunkSize = 1 << 16
+
+// ReadLength reads n bytes claimed by a length prefix, within the limit of
+// rd. Large lengths are read in chunks, so that a length with no data behind
+// it does not allocate its size.
+func ReadLength(rd io.Reader, n uint64) ([]byte, error) {
+	if e := CheckLength(rd, n); e != nil {
+		return nil, e
+	}
+
+	if n <= chunkSize {
+		r := make([]byte, n)
+		if _, e := io.ReadFull(rd, r); e != nil {
+			return nil, e
+		}
+		return r, nil
+	}
+
+	if n > math.MaxInt64 {
+		return nil, &ErrTooLarge{Length: n, Max: math.MaxInt64}
+	}
+
+	buf := &bytes.Buffer{}
+	if _, e := io.CopyN(buf, rd, int64(n)); e != nil {
+		if e == io.EOF {
+			e = io.ErrUnexpectedEOF
+		}
+		return nil, e
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,73 @@
+package data_types
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/xhebox/bstruct/byteorder"
+)
+
+func tooLarge(t *testing.T, e error, length uint64) {
+	t.Helper()
+
+	var te *ErrTooLarge
+	if !errors.As(e, &te) {
+		t.Fatalf("got %v, want too large", e)
+	}
+
+	if te.Length != length {
+		t.Fatalf("got length %d, want %d", te.Length, length)
+	}
+}
+
+func TestLimitLength(t *testing.T) {
+	buf := &bytes.Buffer{}
+	byteorder.PutUVarint(buf, byteorder.BigEndian, 1<<40)
+	claim := buf.Bytes()
+
+	b := ByteArray{}
+	tooLarge(t, b.Read(LimitLength(bytes.NewReader(claim), 1<<20), byteorder.BigEndian), 1<<40)
+
+	// without a limit, the missing data fails before anything is allocated
+	if e := b.Read(bytes.NewReader(claim), byteorder.BigEndian); e != io.ErrUnexpectedEOF {
+		t.Fatalf("got %v", e)
+	}
+
+	_, e := ReadString(LimitLength(bytes.NewReader(claim), 1<<20), byteorder.BigEndian)
+	tooLarge(t, e, 1<<40)
+
+	l := List[Varint, *Varint]{}
+	tooLarge(t, l.Read(LimitLength(bytes.NewReader(claim), 16), byteorder.BigEndian), 1<<40)
+
+	m := Map[String, Varint, *String, *Varint]{}
+	tooLarge(t, m.Read(LimitLength(bytes.NewReader(claim), 16), byteorder.BigEndian), 1<<40)
+
+	var v struct {
+		A []int32 `sb:"list,i32"`
+	}
+	_, e = DecodeBuf(claim, byteorder.BigEndian, &v)
+	tooLarge(t, e, 1<<40)
+
+	// within the limit, large strings are read in chunks
+	want := bytes.Repeat([]byte("sb"), chunkSize)
+	buf.Reset()
+	s := String(want)
+	if e := s.Write(buf, byteorder.BigEndian); e != nil {
+		t.Fatal(e)
+	}
+
+	got, e := ReadString(LimitLength(bytes.NewReader(buf.Bytes()), uint64(len(want))), byteorder.BigEndian)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if string(got) != string(want) {
+		t.Fatalf("got %d bytes, want %d", len(got), len(want))
+	}
+
+	if _, e := ReadString(bytes.NewReader(buf.Bytes()[:buf.Len()-1]), byteorder.BigEndian); e != io.ErrUnexpectedEOF {
+		t.Fatalf("got %v", e)
+	}
+}
@@ -0,0 +1,334 @@
+package data_types
+
+import (
+	"bytes"
+	"io"
+	"sort"
+
+	"github.com/xhebox/bstruct/byteorder"
+)
+
+// List is a count followed by that many elements.
+type List[T any, P SerializerPtr[T]] []T
+
+func (this *List[T, P]) Read(rd io.Reader, endian byteorder.ByteOrder) error {
+	u := UVarint(0)
+
+	e := u.Read(rd, endian)
+	if e != nil {
+		return e
+	}
+
+	if e := CheckLength(rd, uint64(u)); e != nil {
+		return e
+	}
+
+	// the count is not trusted to preallocate
+	r := List[T, P]{}
+	for i := uint64(0); i < uint64(u); i++ {
+		var v T
+		if e := P(&v).Read(rd, endian); e != nil {
+			return e
+		}
+		r = append(r, v)
+	}
+
+	*this = r
+	return nil
+}
+
+func (this *List[T, P]) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	u := UVarint(0)
+
+	l, e := u.ReadBuf(buf, endian)
+	if e != nil {
+		return 0, e
+	}
+
+	// every element takes a byte at least
+	if uint64(u) > uint64(len(buf)-l) {
+		return l, errShortRead
+	}
+
+	r := make(List[T, P], u)
+	for i := range r {
+		if l >= len(buf) {
+			return l, errShortRead
+		}
+
+		n, e := P(&r[i]).ReadBuf(buf[l:], endian)
+		l += n
+		if e != nil {
+			return l, e
+		}
+	}
+
+	*this = r
+	return l, nil
+}
+
+func (this *List[T, P]) Write(wt io.Writer, endian byteorder.ByteOrder) error {
+	u := UVarint(len(*this))
+
+	e := u.Write(wt, endian)
+	if e != nil {
+		return e
+	}
+
+	for i := range *this {
+		if e := P(&(*this)[i]).Write(wt, endian); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+func (this *List[T, P]) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	u := UVarint(len(*this))
+
+	l, e := u.WriteBuf(buf, endian)
+	if e != nil {
+		return 0, e
+	}
+
+	for i := range *this {
+		n, e := P(&(*this)[i]).WriteBuf(buf[l:], endian)
+		l += n
+		if e != nil {
+			return l, e
+		}
+	}
+
+	return l, nil
+}
+
+// sortEncoded sorts l by the bytes of its elements, so that writing a set or
+// a map gives the same bytes whatever the order of the Go map.
+func sortEncoded[T any, P SerializerPtr[T]](l []T, endian byteorder.ByteOrder) error {
+	enc := make([][]byte, len(l))
+	for i := range l {
+		buf := &bytes.Buffer{}
+		if e := P(&l[i]).Write(buf, endian); e != nil {
+			return e
+		}
+		enc[i] = buf.Bytes()
+	}
+
+	sort.Sort(byEncoding[T]{l, enc})
+	return nil
+}
+
+type byEncoding[T any] struct {
+	l   []T
+	enc [][]byte
+}
+
+func (b byEncoding[T]) Len() int           { return len(b.l) }
+func (b byEncoding[T]) Less(i, j int) bool { return bytes.Compare(b.enc[i], b.enc[j]) < 0 }
+func (b byEncoding[T]) Swap(i, j int) {
+	b.l[i], b.l[j] = b.l[j], b.l[i]
+	b.enc[i], b.enc[j] = b.enc[j], b.enc[i]
+}
+
+// Set is a List of distinct elements. It is written sorted by the bytes of
+// its elements.
+type Set[T comparable, P SerializerPtr[T]] map[T]struct{}
+
+func (this *Set[T, P]) Read(rd io.Reader, endian byteorder.ByteOrder) error {
+	l := List[T, P]{}
+
+	if e := l.Read(rd, endian); e != nil {
+		return e
+	}
+
+	*this = this.from(l)
+	return nil
+}
+
+func (this *Set[T, P]) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	l := List[T, P]{}
+
+	n, e := l.ReadBuf(buf, endian)
+	if e != nil {
+		return n, e
+	}
+
+	*this = this.from(l)
+	return n, nil
+}
+
+func (this *Set[T, P]) from(l List[T, P]) Set[T, P] {
+	r := make(Set[T, P], len(l))
+	for _, v := range l {
+		r[v] = struct{}{}
+	}
+	return r
+}
+
+func (this *Set[T, P]) list(endian byteorder.ByteOrder) (List[T, P], error) {
+	r := make(List[T, P], 0, len(*this))
+	for v := range *this {
+		r = append(r, v)
+	}
+	return r, sortEncoded[T, P](r, endian)
+}
+
+func (this *Set[T, P]) Write(wt io.Writer, endian byteorder.ByteOrder) error {
+	l, e := this.list(endian)
+	if e != nil {
+		return e
+	}
+
+	return l.Write(wt, endian)
+}
+
+func (this *Set[T, P]) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	l, e := this.list(endian)
+	if e != nil {
+		return 0, e
+	}
+
+	return l.WriteBuf(buf, endian)
+}
+
+// Map is a count followed by that many keys, each followed by its value.
+// It is written sorted by the bytes of its keys.
+type Map[K comparable, V any, PK SerializerPtr[K], PV SerializerPtr[V]] map[K]V
+
+func (this *Map[K, V, PK, PV]) Read(rd io.Reader, endian byteorder.ByteOrder) error {
+	u := UVarint(0)
+
+	e := u.Read(rd, endian)
+	if e != nil {
+		return e
+	}
+
+	if e := CheckLength(rd, uint64(u)); e != nil {
+		return e
+	}
+
+	r := Map[K, V, PK, PV]{}
+	for i := uint64(0); i < uint64(u); i++ {
+		var k K
+		var v V
+
+		if e := PK(&k).Read(rd, endian); e != nil {
+			return e
+		}
+
+		if e := PV(&v).Read(rd, endian); e != nil {
+			return e
+		}
+
+		r[k] = v
+	}
+
+	*this = r
+	return nil
+}
+
+func (this *Map[K, V, PK, PV]) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	u := UVarint(0)
+
+	l, e := u.ReadBuf(buf, endian)
+	if e != nil {
+		return 0, e
+	}
+
+	// every pair takes two bytes at least
+	if uint64(u) > uint64(len(buf)-l)/2 {
+		return l, errShortRead
+	}
+
+	r := make(Map[K, V, PK, PV], u)
+	for i := uint64(0); i < uint64(u); i++ {
+		var k K
+		var v V
+
+		for _, p := range []BufReader{PK(&k), PV(&v)} {
+			if l >= len(buf) {
+				return l, errShortRead
+			}
+
+			n, e := p.ReadBuf(buf[l:], endian)
+			l += n
+			if e != nil {
+				return l, e
+			}
+		}
+
+		r[k] = v
+	}
+
+	*this = r
+	return l, nil
+}
+
+func (this *Map[K, V, PK, PV]) keys(endian byteorder.ByteOrder) ([]K, error) {
+	r := make([]K, 0, len(*this))
+	for k := range *this {
+		r = append(r, k)
+	}
+	return r, sortEncoded[K, PK](r, endian)
+}
+
+func (this *Map[K, V, PK, PV]) Write(wt io.Writer, endian byteorder.ByteOrder) error {
+	u := UVarint(len(*this))
+
+	e := u.Write(wt, endian)
+	if e != nil {
+		return e
+	}
+
+	keys, e := this.keys(endian)
+	if e != nil {
+		return e
+	}
+
+	for _, k := range keys {
+		v := (*this)[k]
+
+		if e := PK(&k).Write(wt, endian); e != nil {
+			return e
+		}
+
+		if e := PV(&v).Write(wt, endian); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+func (this *Map[K, V, PK, PV]) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	u := UVarint(len(*this))
+
+	l, e := u.WriteBuf(buf, endian)
+	if e != nil {
+		return 0, e
+	}
+
+	keys, e := this.keys(endian)
+	if e != nil {
+		return l, e
+	}
+
+	for _, k := range keys {
+		v := (*this)[k]
+
+		n, e := PK(&k).WriteBuf(buf[l:], endian)
+		l += n
+		if e != nil {
+			return l, e
+		}
+
+		n, e = PV(&v).WriteBuf(buf[l:], endian)
+		l += n
+		if e != nil {
+			return l, e
+		}
+	}
+
+	return l, nil
+}
@@ -1,6 +1,8 @@
 package data_types
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -8,22 +10,152 @@ import (
 	"github.com/xhebox/bstruct/byteorder"
 )
 
-type Maybe struct {
+// Maybe is a bool, true if a value follows, then the value.
+type Maybe[T any, P SerializerPtr[T]] struct {
+	value T
+	ok    bool
+}
+
+func Some[T any, P SerializerPtr[T]](v T) Maybe[T, P] {
+	return Maybe[T, P]{value: v, ok: true}
+}
+
+func None[T any, P SerializerPtr[T]]() Maybe[T, P] {
+	return Maybe[T, P]{}
+}
+
+// Get returns the value, and whether there is one.
+func (this Maybe[T, P]) Get() (T, bool) {
+	return this.value, this.ok
+}
+
+func (this *Maybe[T, P]) Read(rd io.Reader, endian byteorder.ByteOrder) error {
+	b, e := byteorder.Bool(rd)
+	if e != nil {
+		return e
+	}
+
+	var v T
+	if b {
+		if e := P(&v).Read(rd, endian); e != nil {
+			return e
+		}
+	}
+
+	this.value, this.ok = v, b
+	return nil
+}
+
+func (this *Maybe[T, P]) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 1 {
+		return 0, errShortRead
+	}
+
+	var v T
+	l := 1
+
+	b := byteorder.Byte2Bool(buf[0])
+	if b {
+		if len(buf) < 2 {
+			return 1, errShortRead
+		}
+
+		n, e := P(&v).ReadBuf(buf[1:], endian)
+		l += n
+		if e != nil {
+			return l, e
+		}
+	}
+
+	this.value, this.ok = v, b
+	return l, nil
+}
+
+func (this *Maybe[T, P]) Write(wt io.Writer, endian byteorder.ByteOrder) error {
+	if e := byteorder.PutBool(wt, this.ok); e != nil || !this.ok {
+		return e
+	}
+
+	return P(&this.value).Write(wt, endian)
+}
+
+func (this *Maybe[T, P]) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 1 {
+		return 0, errShortWrite
+	}
+
+	buf[0] = byteorder.Bool2Byte(this.ok)
+	if !this.ok {
+		return 1, nil
+	}
+
+	l, e := P(&this.value).WriteBuf(buf[1:], endian)
+	return l + 1, e
+}
+
+// MarshalJSON writes null, or the value.
+func (this Maybe[T, P]) MarshalJSON() ([]byte, error) {
+	if !this.ok {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(this.value)
+}
+
+func (this *Maybe[T, P]) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		*this = Maybe[T, P]{}
+		return nil
+	}
+
+	var v T
+	if e := json.Unmarshal(data, &v); e != nil {
+		return e
+	}
+
+	*this = Maybe[T, P]{value: v, ok: true}
+	return nil
+}
+
+func (this Maybe[T, P]) String() string {
+	if !this.ok {
+		return "None"
+	}
+
+	return fmt.Sprint(this.value)
+}
+
+// MaybeContent is a Maybe of a value known at run time.
+//
+// Deprecated: use Maybe, which has a type. A value is written if Present is
+// set, or, as before Present existed, if Content is not nil. Once read, only
+// Present counts: Read keeps Content to read into when the value is absent,
+// so a value read back writes the same bytes.
+type MaybeContent struct {
 	Content interface {
 		Reader
 		BufReader
 		Writer
 		BufWriter
 	}
+	Present bool
+
+	read bool
 }
 
-func (this *Maybe) Read(rd io.Reader, endian byteorder.ByteOrder) error {
-	var c [1]byte
-	if _, e := io.ReadFull(rd, c[:]); e != nil {
+func (this *MaybeContent) present() bool {
+	return this.Present || !this.read && this.Content != nil
+}
+
+func (this *MaybeContent) Read(rd io.Reader, endian byteorder.ByteOrder) error {
+	b, e := byteorder.Bool(rd)
+	if e != nil {
 		return e
 	}
 
-	if c[0] == 0 {
+	this.Present = b
+	this.read = true
+	if !b {
 		return nil
 	}
 
@@ -34,8 +166,14 @@ func (this *Maybe) Read(rd io.Reader, endian byteorder.ByteOrder) error {
 	return this.Content.Read(rd, endian)
 }
 
-func (this *Maybe) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
-	if buf[0] == 0 {
+func (this *MaybeContent) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 1 {
+		return 0, errShortRead
+	}
+
+	this.Present = byteorder.Byte2Bool(buf[0])
+	this.read = true
+	if !this.Present {
 		return 1, nil
 	}
 
@@ -47,31 +185,32 @@ func (this *Maybe) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error)
 	return l + 1, e
 }
 
-func (this *Maybe) Write(wt io.Writer, endian byteorder.ByteOrder) error {
-	var c [1]byte
-
-	b := this.Content == nil
-
-	c[0] = byteorder.Bool2Byte(b)
-
-	_, e := wt.Write(c[:])
-	if e != nil {
-		return e
+func (this *MaybeContent) Write(wt io.Writer, endian byteorder.ByteOrder) error {
+	present := this.present()
+	if present && this.Content == nil {
+		return errors.New("need to specific a type first")
 	}
 
-	if b {
-		return nil
+	if e := byteorder.PutBool(wt, present); e != nil || !present {
+		return e
 	}
 
 	return this.Content.Write(wt, endian)
 }
 
-func (this *Maybe) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
-	b := this.Content == nil
+func (this *MaybeContent) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 1 {
+		return 0, errShortWrite
+	}
+
+	present := this.present()
+	if present && this.Content == nil {
+		return 0, errors.New("need to specific a type first")
+	}
 
-	buf[0] = byteorder.Bool2Byte(b)
+	buf[0] = byteorder.Bool2Byte(present)
 
-	if b {
+	if !present {
 		return 1, nil
 	}
 
@@ -79,6 +218,6 @@ func (this *Maybe) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error)
 	return l + 1, e
 }
 
-func (this *Maybe) String() string {
+func (this *MaybeContent) String() string {
 	return fmt.Sprint(this.Content)
 }
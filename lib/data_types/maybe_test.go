@@ -0,0 +1,116 @@
+package data_types
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/xhebox/bstruct/byteorder"
+)
+
+func TestMaybe(t *testing.T) {
+	roundTrip(t, Some[String]("x"), []byte{1, 1, 'x'})
+	roundTrip(t, None[String](), []byte{0})
+	roundTrip(t, Some[List[Varint, *Varint]](List[Varint, *Varint]{1}), []byte{1, 1, 2})
+
+	if v, ok := Some[Varint](3).Get(); !ok || v != 3 {
+		t.Fatalf("some: %v %v", v, ok)
+	}
+
+	if _, ok := None[Varint]().Get(); ok {
+		t.Fatal("none has a value")
+	}
+
+	// reading an absent value clears the old one
+	m := Some[String]("old")
+	if e := m.Read(bytes.NewReader([]byte{0}), byteorder.BigEndian); e != nil {
+		t.Fatal(e)
+	}
+	if _, ok := m.Get(); ok {
+		t.Fatal("absent value kept the old one")
+	}
+
+	m = Some[String]("old")
+	if _, e := m.ReadBuf([]byte{0}, byteorder.BigEndian); e != nil {
+		t.Fatal(e)
+	}
+	if _, ok := m.Get(); ok {
+		t.Fatal("absent value kept the old one")
+	}
+
+	type doc struct {
+		A Maybe[String, *String]
+		B Maybe[Varint, *Varint]
+	}
+
+	data, e := json.Marshal(doc{A: Some[String]("s")})
+	if e != nil || string(data) != `{"A":"s","B":null}` {
+		t.Fatalf("marshal %s %v", data, e)
+	}
+
+	var r doc
+	if e := json.Unmarshal([]byte(`{"A":null,"B":5}`), &r); e != nil {
+		t.Fatal(e)
+	}
+	if _, ok := r.A.Get(); ok {
+		t.Fatal("null unmarshalled to a value")
+	}
+	if v, ok := r.B.Get(); !ok || v != 5 {
+		t.Fatalf("unmarshal %v %v", v, ok)
+	}
+}
+
+func TestMaybeContent(t *testing.T) {
+	s := String("x")
+
+	buf := &bytes.Buffer{}
+	if e := (&MaybeContent{Content: &s, Present: true}).Write(buf, byteorder.BigEndian); e != nil || !bytes.Equal(buf.Bytes(), []byte{1, 1, 'x'}) {
+		t.Fatalf("present: % x %v", buf.Bytes(), e)
+	}
+
+	buf.Reset()
+	if e := (&MaybeContent{}).Write(buf, byteorder.BigEndian); e != nil || !bytes.Equal(buf.Bytes(), []byte{0}) {
+		t.Fatalf("absent: % x %v", buf.Bytes(), e)
+	}
+
+	// a literal from before Present is written when it has a content
+	buf.Reset()
+	if e := (&MaybeContent{Content: &s}).Write(buf, byteorder.BigEndian); e != nil || !bytes.Equal(buf.Bytes(), []byte{1, 1, 'x'}) {
+		t.Fatalf("content only: % x %v", buf.Bytes(), e)
+	}
+
+	b := make([]byte, 1)
+	if n, e := (&MaybeContent{}).WriteBuf(b, byteorder.BigEndian); e != nil || n != 1 || b[0] != 0 {
+		t.Fatalf("absent buf: % x %v", b, e)
+	}
+
+	var r String
+	m := &MaybeContent{Content: &r}
+	if e := m.Read(bytes.NewReader([]byte{1, 1, 'y'}), byteorder.BigEndian); e != nil || !m.Present || r != "y" {
+		t.Fatalf("read %v %q %v", m.Present, r, e)
+	}
+
+	if n, e := m.ReadBuf([]byte{0}, byteorder.BigEndian); e != nil || n != 1 || m.Present {
+		t.Fatalf("readbuf %d %v %v", n, m.Present, e)
+	}
+
+	// absent with a content to read into, it must stay absent when written back
+	m = &MaybeContent{Content: &s}
+	if e := m.Read(bytes.NewReader([]byte{0}), byteorder.BigEndian); e != nil {
+		t.Fatal(e)
+	}
+
+	buf.Reset()
+	if e := m.Write(buf, byteorder.BigEndian); e != nil || !bytes.Equal(buf.Bytes(), []byte{0}) {
+		t.Fatalf("written back % x %v", buf.Bytes(), e)
+	}
+
+	b = make([]byte, 3)
+	if n, e := m.WriteBuf(b, byteorder.BigEndian); e != nil || n != 1 || b[0] != 0 {
+		t.Fatalf("written back buf % x %v", b[:n], e)
+	}
+
+	if e := (&MaybeContent{Present: true}).Write(&bytes.Buffer{}, byteorder.BigEndian); e == nil {
+		t.Fatal("present without a content")
+	}
+}
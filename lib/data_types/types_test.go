@@ -0,0 +1,147 @@
+package data_types
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/xhebox/bstruct/byteorder"
+)
+
+// roundTrip writes v both ways, compares the bytes with want unless it is
+// nil, and reads them back both ways.
+func roundTrip[T any, P SerializerPtr[T]](t *testing.T, v T, want []byte) {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	if e := P(&v).Write(buf, byteorder.BigEndian); e != nil {
+		t.Fatalf("%T write: %v", v, e)
+	}
+
+	if want != nil && !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("%T: wrote % x, want % x", v, buf.Bytes(), want)
+	}
+
+	b := make([]byte, buf.Len()+byteorder.VMAXLEN)
+	n, e := P(&v).WriteBuf(b, byteorder.BigEndian)
+	if e != nil || n != buf.Len() {
+		t.Fatalf("%T writebuf: %d %v", v, n, e)
+	}
+
+	if want != nil && !bytes.Equal(b[:n], want) {
+		t.Fatalf("%T: wrotebuf % x, want % x", v, b[:n], want)
+	}
+
+	var r T
+	if e := P(&r).Read(bytes.NewReader(buf.Bytes()), byteorder.BigEndian); e != nil {
+		t.Fatalf("%T read: %v", v, e)
+	}
+
+	if !reflect.DeepEqual(r, v) {
+		t.Fatalf("read %+v, want %+v", r, v)
+	}
+
+	var rb T
+	n, e = P(&rb).ReadBuf(buf.Bytes(), byteorder.BigEndian)
+	if e != nil || n != buf.Len() {
+		t.Fatalf("%T readbuf: %d %v", v, n, e)
+	}
+
+	if !reflect.DeepEqual(rb, v) {
+		t.Fatalf("readbuf %+v, want %+v", rb, v)
+	}
+
+	if _, e := P(&rb).ReadBuf(buf.Bytes()[:buf.Len()-1], byteorder.BigEndian); e == nil {
+		t.Fatalf("%T: truncated readbuf succeeded", v)
+	}
+}
+
+func TestList(t *testing.T) {
+	roundTrip(t, List[String, *String]{"a", "bc"}, []byte{2, 1, 'a', 2, 'b', 'c'})
+	roundTrip(t, List[Varint, *Varint]{1, -1, 300}, nil)
+	roundTrip(t, List[List[Varint, *Varint], *List[Varint, *Varint]]{{1}, {2, 3}}, []byte{2, 1, 2, 2, 4, 6})
+
+	var l List[String, *String]
+	if _, e := l.ReadBuf([]byte{0x7f}, byteorder.BigEndian); e == nil {
+		t.Fatal("count past the end accepted")
+	}
+}
+
+// Sets and maps are sorted by the bytes of their keys, whatever the order of
+// the Go map, so every write gives the same bytes.
+func TestSet(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		roundTrip(t, Set[String, *String]{"b": {}, "a": {}, "ab": {}, "c": {}}, []byte{4, 1, 'a', 1, 'b', 1, 'c', 2, 'a', 'b'})
+		roundTrip(t, Set[Varint, *Varint]{7: {}, -1: {}, 0: {}, 64: {}}, []byte{4, 0, 1, 14, 0x81, 0})
+	}
+}
+
+func TestMap(t *testing.T) {
+	roundTrip(t, Map[String, Varint, *String, *Varint]{"k": -2}, []byte{1, 1, 'k', 3})
+
+	for i := 0; i < 20; i++ {
+		roundTrip(t, Map[Varint, List[String, *String], *Varint, *List[String, *String]]{2: {}, 1: {"x"}, 3: {"y", "z"}}, []byte{3, 2, 1, 1, 'x', 4, 0, 6, 2, 1, 'y', 1, 'z'})
+	}
+}
+
+func TestVectors(t *testing.T) {
+	roundTrip(t, Vec2I{-1, 2}, []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 2})
+	roundTrip(t, Vec2U{1, 0xffffffff}, []byte{0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff})
+	roundTrip(t, Vec2F{1, -0.5}, []byte{0x3f, 0x80, 0, 0, 0xbf, 0, 0, 0})
+	roundTrip(t, Vec3I{1, 2, 3}, []byte{0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3})
+	roundTrip(t, RectI{Min: Vec2I{1, 2}, Max: Vec2I{3, 4}}, []byte{0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0, 4})
+	roundTrip(t, RectF{Min: Vec2F{0, 0.5}, Max: Vec2F{1.5, 2}}, nil)
+	roundTrip(t, Color{1, 0.5, 0, 1}, []byte{0x3f, 0x80, 0, 0, 0x3f, 0, 0, 0, 0, 0, 0, 0, 0x3f, 0x80, 0, 0})
+}
+
+func TestVariant(t *testing.T) {
+	types := []func() Serializer{
+		func() Serializer { return new(String) },
+		func() Serializer { return new(Vec2I) },
+	}
+
+	s := String("hi")
+	for _, c := range []struct {
+		v    Variant
+		want []byte
+	}{
+		{Variant{Types: types, Index: 0, Content: &s}, []byte{0, 2, 'h', 'i'}},
+		{Variant{Types: types, Index: 1, Content: &Vec2I{3, 4}}, []byte{1, 0, 0, 0, 3, 0, 0, 0, 4}},
+	} {
+		buf := &bytes.Buffer{}
+		if e := c.v.Write(buf, byteorder.BigEndian); e != nil || !bytes.Equal(buf.Bytes(), c.want) {
+			t.Fatalf("wrote % x %v, want % x", buf.Bytes(), e, c.want)
+		}
+
+		b := make([]byte, len(c.want)+byteorder.VMAXLEN)
+		if n, e := c.v.WriteBuf(b, byteorder.BigEndian); e != nil || !bytes.Equal(b[:n], c.want) {
+			t.Fatalf("wrotebuf % x %v, want % x", b[:n], e, c.want)
+		}
+
+		r := Variant{Types: types}
+		if e := r.Read(buf, byteorder.BigEndian); e != nil || r.Index != c.v.Index || !reflect.DeepEqual(r.Content, c.v.Content) {
+			t.Fatalf("read %d %v %v", r.Index, r.Content, e)
+		}
+
+		rb := Variant{Types: types}
+		if n, e := rb.ReadBuf(c.want, byteorder.BigEndian); e != nil || n != len(c.want) || !reflect.DeepEqual(rb.Content, c.v.Content) {
+			t.Fatalf("readbuf %d %v %v", n, rb.Content, e)
+		}
+	}
+
+	v := Variant{Types: types}
+	if e := v.Read(bytes.NewReader([]byte{2, 0}), byteorder.BigEndian); e == nil {
+		t.Fatal("index out of range accepted")
+	}
+
+	if e := v.Write(&bytes.Buffer{}, byteorder.BigEndian); e == nil {
+		t.Fatal("empty variant written")
+	}
+}
+
+func TestEither(t *testing.T) {
+	type either = Either[String, Varint, *String, *Varint]
+
+	roundTrip(t, either{IsLeft: true, Left: "l"}, []byte{1, 1, 'l'})
+	roundTrip(t, either{Right: -3}, []byte{0, 5})
+}
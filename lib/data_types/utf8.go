@@ -0,0 +1,148 @@
+package data_types
+
+import (
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/xhebox/bstruct/byteorder"
+)
+
+// StringPolicy is what to do with a string that the game would refuse:
+// invalid utf-8, which includes sequences longer than 4 bytes.
+type StringPolicy byte
+
+const (
+	// Passthrough writes strings as they are.
+	Passthrough StringPolicy = iota
+	// Reject fails with an *InvalidUTF8Error.
+	Reject
+	// Replace substitutes an 'I' for each invalid sequence, like sbutf8.
+	Replace
+)
+
+func (p StringPolicy) String() string {
+	switch p {
+	case Passthrough:
+		return "passthrough"
+	case Reject:
+		return "reject"
+	case Replace:
+		return "replace"
+	}
+
+	return "unknown"
+}
+
+func ParseStringPolicy(s string) (StringPolicy, error) {
+	switch s {
+	case "passthrough":
+		return Passthrough, nil
+	case "reject":
+		return Reject, nil
+	case "replace":
+		return Replace, nil
+	}
+
+	return Passthrough, fmt.Errorf("unknown string policy %s", s)
+}
+
+// InvalidUTF8Error locates the first invalid sequence of a string.
+type InvalidUTF8Error struct {
+	Offset int
+}
+
+func (e *InvalidUTF8Error) Error() string {
+	return fmt.Sprintf("invalid utf-8 at byte %d", e.Offset)
+}
+
+// seqLen is the length of the sequence that c starts, as sbutf8 skips it when
+// it is invalid.
+func seqLen(c byte) int {
+	switch {
+	case c < 0xC0:
+		return 1
+	case c < 0xE0:
+		return 2
+	case c < 0xF0:
+		return 3
+	case c < 0xF8:
+		return 4
+	}
+
+	return 1
+}
+
+// InvalidUTF8 returns the offset of the first invalid sequence of s, or -1.
+func InvalidUTF8(s string) int {
+	for i := 0; i < len(s); {
+		if s[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+
+		r, n := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && n == 1 {
+			return i
+		}
+		i += n
+	}
+
+	return -1
+}
+
+// SanitizeUTF8 replaces each invalid sequence of s by an 'I'. Like sbutf8, a
+// bad sequence swallows as many bytes as its first byte announces.
+func SanitizeUTF8(s string) string {
+	i := InvalidUTF8(s)
+	if i == -1 {
+		return s
+	}
+
+	r := make([]byte, 0, len(s))
+	r = append(r, s[:i]...)
+
+	for i < len(s) {
+		if s[i] < utf8.RuneSelf {
+			r = append(r, s[i])
+			i++
+			continue
+		}
+
+		c, n := utf8.DecodeRuneInString(s[i:])
+		if c != utf8.RuneError || n != 1 {
+			r = append(r, s[i:i+n]...)
+			i += n
+			continue
+		}
+
+		r = append(r, 'I')
+		i += seqLen(s[i])
+	}
+
+	return string(r)
+}
+
+// Apply returns s as the policy writes it.
+func (p StringPolicy) Apply(s String) (String, error) {
+	switch p {
+	case Reject:
+		if i := InvalidUTF8(string(s)); i != -1 {
+			return s, &InvalidUTF8Error{Offset: i}
+		}
+	case Replace:
+		return String(SanitizeUTF8(string(s))), nil
+	}
+
+	return s, nil
+}
+
+// WritePolicy is Write with the string going through p first.
+func (this *String) WritePolicy(wt io.Writer, endian byteorder.ByteOrder, p StringPolicy) error {
+	s, e := p.Apply(*this)
+	if e != nil {
+		return e
+	}
+
+	return s.Write(wt, endian)
+}
@@ -0,0 +1,65 @@
+package data_types
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/xhebox/bstruct/byteorder"
+)
+
+func TestUTF8(t *testing.T) {
+	for s, want := range map[string]struct {
+		off       int
+		sanitized string
+	}{
+		"":                      {-1, ""},
+		"abc":                   {-1, "abc"},
+		"中文𝗗":                   {-1, "中文𝗗"},
+		"a\x80b":                {1, "aIb"},
+		"a\xc3":                 {1, "aI"},
+		"a\xc3bc":               {1, "aIc"},
+		"\xe4\xb8x\xe4\xb8\xad": {0, "I中"},
+		"\xf8\x88\x80\x80\x80":  {0, "IIIII"},
+		"\xed\xa0\x80z":         {0, "Iz"},
+		"\xf4\x90\x80\x80z":     {0, "Iz"},
+		"\xc0\xafz":             {0, "Iz"},
+	} {
+		if got := InvalidUTF8(s); got != want.off {
+			t.Errorf("%q: offset %d, want %d", s, got, want.off)
+		}
+
+		if got := SanitizeUTF8(s); got != want.sanitized {
+			t.Errorf("%q: sanitized %q, want %q", s, got, want.sanitized)
+		}
+	}
+}
+
+func TestStringPolicy(t *testing.T) {
+	s := String("ok\xff")
+
+	buf := &bytes.Buffer{}
+	if e := s.WritePolicy(buf, byteorder.BigEndian, Passthrough); e != nil || buf.String() != "\x03ok\xff" {
+		t.Fatalf("passthrough: %q %v", buf.String(), e)
+	}
+
+	buf.Reset()
+	if e := s.WritePolicy(buf, byteorder.BigEndian, Replace); e != nil || buf.String() != "\x03okI" {
+		t.Fatalf("replace: %q %v", buf.String(), e)
+	}
+
+	buf.Reset()
+	e := s.WritePolicy(buf, byteorder.BigEndian, Reject)
+	if u, ok := e.(*InvalidUTF8Error); !ok || u.Offset != 2 || buf.Len() != 0 {
+		t.Fatalf("reject: %q %v", buf.String(), e)
+	}
+
+	for _, p := range []StringPolicy{Passthrough, Reject, Replace} {
+		if r, e := ParseStringPolicy(p.String()); e != nil || r != p {
+			t.Fatalf("%s: %v %v", p, r, e)
+		}
+	}
+
+	if _, e := ParseStringPolicy("drop"); e == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -3,6 +3,7 @@ package data_types
 import (
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 
 	"github.com/xhebox/bstruct/byteorder"
@@ -46,3 +47,16 @@ func (this *UUID) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error)
 func (this *UUID) String() string {
 	return hex.EncodeToString((*this)[:])
 }
+
+// ParseUUID reads the hex form that String returns.
+func ParseUUID(s string) (UUID, error) {
+	r := UUID{}
+
+	b, e := hex.DecodeString(s)
+	if e != nil || len(b) != len(r) {
+		return r, fmt.Errorf("invalid uuid %q", s)
+	}
+
+	copy(r[:], b)
+	return r, nil
+}
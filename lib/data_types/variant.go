@@ -0,0 +1,139 @@
+package data_types
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/xhebox/bstruct/byteorder"
+)
+
+// Variant is the index of the alternative held, as a byte, followed by its
+// value. Types makes an empty value of each alternative, in order, so that
+// it can be read.
+type Variant struct {
+	Types   []func() Serializer
+	Index   uint8
+	Content Serializer
+}
+
+func (this *Variant) content(i uint8) error {
+	if int(i) >= len(this.Types) {
+		return fmt.Errorf("variant index %d out of %d types", i, len(this.Types))
+	}
+
+	this.Index = i
+	this.Content = this.Types[i]()
+	return nil
+}
+
+func (this *Variant) Read(rd io.Reader, endian byteorder.ByteOrder) error {
+	i, e := byteorder.Uint8(rd)
+	if e != nil {
+		return e
+	}
+
+	if e := this.content(i); e != nil {
+		return e
+	}
+
+	return this.Content.Read(rd, endian)
+}
+
+func (this *Variant) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 2 {
+		return 0, errShortRead
+	}
+
+	if e := this.content(buf[0]); e != nil {
+		return 1, e
+	}
+
+	l, e := this.Content.ReadBuf(buf[1:], endian)
+	return l + 1, e
+}
+
+func (this *Variant) Write(wt io.Writer, endian byteorder.ByteOrder) error {
+	if this.Content == nil {
+		return errors.New("empty variant")
+	}
+
+	if e := byteorder.PutUint8(wt, this.Index); e != nil {
+		return e
+	}
+
+	return this.Content.Write(wt, endian)
+}
+
+func (this *Variant) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if this.Content == nil {
+		return 0, errors.New("empty variant")
+	}
+
+	if len(buf) < 1 {
+		return 0, errShortWrite
+	}
+
+	buf[0] = this.Index
+
+	l, e := this.Content.WriteBuf(buf[1:], endian)
+	return l + 1, e
+}
+
+func (this *Variant) String() string {
+	return fmt.Sprint(this.Content)
+}
+
+// Either is a bool, true for Left, followed by the value of that side.
+type Either[L, R any, PL SerializerPtr[L], PR SerializerPtr[R]] struct {
+	IsLeft bool
+	Left   L
+	Right  R
+}
+
+func (this *Either[L, R, PL, PR]) side() Serializer {
+	if this.IsLeft {
+		return PL(&this.Left)
+	}
+	return PR(&this.Right)
+}
+
+func (this *Either[L, R, PL, PR]) Read(rd io.Reader, endian byteorder.ByteOrder) error {
+	b, e := byteorder.Bool(rd)
+	if e != nil {
+		return e
+	}
+
+	this.IsLeft = b
+	return this.side().Read(rd, endian)
+}
+
+func (this *Either[L, R, PL, PR]) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 2 {
+		return 0, errShortRead
+	}
+
+	this.IsLeft = byteorder.Byte2Bool(buf[0])
+
+	l, e := this.side().ReadBuf(buf[1:], endian)
+	return l + 1, e
+}
+
+func (this *Either[L, R, PL, PR]) Write(wt io.Writer, endian byteorder.ByteOrder) error {
+	if e := byteorder.PutBool(wt, this.IsLeft); e != nil {
+		return e
+	}
+
+	return this.side().Write(wt, endian)
+}
+
+func (this *Either[L, R, PL, PR]) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 1 {
+		return 0, errShortWrite
+	}
+
+	buf[0] = byteorder.Bool2Byte(this.IsLeft)
+
+	l, e := this.side().WriteBuf(buf[1:], endian)
+	return l + 1, e
+}
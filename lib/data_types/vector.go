@@ -0,0 +1,261 @@
+package data_types
+
+import (
+	"io"
+
+	"github.com/xhebox/bstruct/byteorder"
+)
+
+// readFixed and writeFixed go through the buffer methods of a type of a
+// fixed size.
+func readFixed(rd io.Reader, endian byteorder.ByteOrder, size int, v BufReader) error {
+	buf := make([]byte, size)
+	if _, e := io.ReadFull(rd, buf); e != nil {
+		return e
+	}
+
+	_, e := v.ReadBuf(buf, endian)
+	return e
+}
+
+func writeFixed(wt io.Writer, endian byteorder.ByteOrder, size int, v BufWriter) error {
+	buf := make([]byte, size)
+	if _, e := v.WriteBuf(buf, endian); e != nil {
+		return e
+	}
+
+	_, e := wt.Write(buf)
+	return e
+}
+
+// Vec2I is two int32, x then y.
+type Vec2I [2]int32
+
+func (this *Vec2I) Read(rd io.Reader, endian byteorder.ByteOrder) error {
+	return readFixed(rd, endian, 8, this)
+}
+
+func (this *Vec2I) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 8 {
+		return 0, errShortRead
+	}
+
+	for i := range this {
+		this[i] = endian.Int32(buf[i*4:])
+	}
+	return 8, nil
+}
+
+func (this *Vec2I) Write(wt io.Writer, endian byteorder.ByteOrder) error {
+	return writeFixed(wt, endian, 8, this)
+}
+
+func (this *Vec2I) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 8 {
+		return 0, errShortWrite
+	}
+
+	for i := range this {
+		endian.PutInt32(buf[i*4:], this[i])
+	}
+	return 8, nil
+}
+
+// Vec2U is two uint32, x then y.
+type Vec2U [2]uint32
+
+func (this *Vec2U) Read(rd io.Reader, endian byteorder.ByteOrder) error {
+	return readFixed(rd, endian, 8, this)
+}
+
+func (this *Vec2U) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 8 {
+		return 0, errShortRead
+	}
+
+	for i := range this {
+		this[i] = endian.Uint32(buf[i*4:])
+	}
+	return 8, nil
+}
+
+func (this *Vec2U) Write(wt io.Writer, endian byteorder.ByteOrder) error {
+	return writeFixed(wt, endian, 8, this)
+}
+
+func (this *Vec2U) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 8 {
+		return 0, errShortWrite
+	}
+
+	for i := range this {
+		endian.PutUint32(buf[i*4:], this[i])
+	}
+	return 8, nil
+}
+
+// Vec2F is two float32, x then y.
+type Vec2F [2]float32
+
+func (this *Vec2F) Read(rd io.Reader, endian byteorder.ByteOrder) error {
+	return readFixed(rd, endian, 8, this)
+}
+
+func (this *Vec2F) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 8 {
+		return 0, errShortRead
+	}
+
+	for i := range this {
+		this[i] = endian.Float32(buf[i*4:])
+	}
+	return 8, nil
+}
+
+func (this *Vec2F) Write(wt io.Writer, endian byteorder.ByteOrder) error {
+	return writeFixed(wt, endian, 8, this)
+}
+
+func (this *Vec2F) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 8 {
+		return 0, errShortWrite
+	}
+
+	for i := range this {
+		endian.PutFloat32(buf[i*4:], this[i])
+	}
+	return 8, nil
+}
+
+// Vec3I is three int32, x, y then z.
+type Vec3I [3]int32
+
+func (this *Vec3I) Read(rd io.Reader, endian byteorder.ByteOrder) error {
+	return readFixed(rd, endian, 12, this)
+}
+
+func (this *Vec3I) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 12 {
+		return 0, errShortRead
+	}
+
+	for i := range this {
+		this[i] = endian.Int32(buf[i*4:])
+	}
+	return 12, nil
+}
+
+func (this *Vec3I) Write(wt io.Writer, endian byteorder.ByteOrder) error {
+	return writeFixed(wt, endian, 12, this)
+}
+
+func (this *Vec3I) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 12 {
+		return 0, errShortWrite
+	}
+
+	for i := range this {
+		endian.PutInt32(buf[i*4:], this[i])
+	}
+	return 12, nil
+}
+
+// RectI is the lower corner, then the upper one.
+type RectI struct {
+	Min, Max Vec2I
+}
+
+func (this *RectI) Read(rd io.Reader, endian byteorder.ByteOrder) error {
+	return readFixed(rd, endian, 16, this)
+}
+
+func (this *RectI) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 16 {
+		return 0, errShortRead
+	}
+
+	this.Min.ReadBuf(buf, endian)
+	this.Max.ReadBuf(buf[8:], endian)
+	return 16, nil
+}
+
+func (this *RectI) Write(wt io.Writer, endian byteorder.ByteOrder) error {
+	return writeFixed(wt, endian, 16, this)
+}
+
+func (this *RectI) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 16 {
+		return 0, errShortWrite
+	}
+
+	this.Min.WriteBuf(buf, endian)
+	this.Max.WriteBuf(buf[8:], endian)
+	return 16, nil
+}
+
+// RectF is the lower corner, then the upper one.
+type RectF struct {
+	Min, Max Vec2F
+}
+
+func (this *RectF) Read(rd io.Reader, endian byteorder.ByteOrder) error {
+	return readFixed(rd, endian, 16, this)
+}
+
+func (this *RectF) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 16 {
+		return 0, errShortRead
+	}
+
+	this.Min.ReadBuf(buf, endian)
+	this.Max.ReadBuf(buf[8:], endian)
+	return 16, nil
+}
+
+func (this *RectF) Write(wt io.Writer, endian byteorder.ByteOrder) error {
+	return writeFixed(wt, endian, 16, this)
+}
+
+func (this *RectF) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 16 {
+		return 0, errShortWrite
+	}
+
+	this.Min.WriteBuf(buf, endian)
+	this.Max.WriteBuf(buf[8:], endian)
+	return 16, nil
+}
+
+// Color is red, green, blue and alpha as float32 from 0 to 1, the way the
+// game writes it.
+type Color [4]float32
+
+func (this *Color) Read(rd io.Reader, endian byteorder.ByteOrder) error {
+	return readFixed(rd, endian, 16, this)
+}
+
+func (this *Color) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 16 {
+		return 0, errShortRead
+	}
+
+	for i := range this {
+		this[i] = endian.Float32(buf[i*4:])
+	}
+	return 16, nil
+}
+
+func (this *Color) Write(wt io.Writer, endian byteorder.ByteOrder) error {
+	return writeFixed(wt, endian, 16, this)
+}
+
+func (this *Color) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 16 {
+		return 0, errShortWrite
+	}
+
+	for i := range this {
+		endian.PutFloat32(buf[i*4:], this[i])
+	}
+	return 16, nil
+}
@@ -0,0 +1,339 @@
+package data_types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xhebox/bstruct/byteorder"
+)
+
+// WorldKind is the kind of world a WorldId names, and its index on the wire.
+type WorldKind uint8
+
+const (
+	NoWorld WorldKind = iota
+	CelestialWorld
+	ClientShipWorld
+	InstanceWorld
+)
+
+func (k WorldKind) String() string {
+	switch k {
+	case NoWorld:
+		return "Nowhere"
+	case CelestialWorld:
+		return "CelestialWorld"
+	case ClientShipWorld:
+		return "ClientShipWorld"
+	case InstanceWorld:
+		return "InstanceWorld"
+	}
+
+	return "unknown"
+}
+
+// InstanceWorldId names an instance, such as a dungeon or a mission, with
+// an optional uuid for separate copies and an optional threat level.
+type InstanceWorldId struct {
+	Name  String
+	Uuid  Maybe[UUID, *UUID]
+	Level Maybe[Float32, *Float32]
+}
+
+func (this *InstanceWorldId) fields() []Serializer {
+	return []Serializer{&this.Name, &this.Uuid, &this.Level}
+}
+
+func (this *InstanceWorldId) Read(rd io.Reader, endian byteorder.ByteOrder) error {
+	r := InstanceWorldId{}
+
+	for _, v := range r.fields() {
+		if e := v.Read(rd, endian); e != nil {
+			return e
+		}
+	}
+
+	*this = r
+	return nil
+}
+
+func (this *InstanceWorldId) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	r := InstanceWorldId{}
+	l := 0
+
+	for _, v := range r.fields() {
+		n, e := v.ReadBuf(buf[l:], endian)
+		l += n
+		if e != nil {
+			return l, e
+		}
+	}
+
+	*this = r
+	return l, nil
+}
+
+func (this *InstanceWorldId) Write(wt io.Writer, endian byteorder.ByteOrder) error {
+	for _, v := range this.fields() {
+		if e := v.Write(wt, endian); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+func (this *InstanceWorldId) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	l := 0
+
+	for _, v := range this.fields() {
+		n, e := v.WriteBuf(buf[l:], endian)
+		l += n
+		if e != nil {
+			return l, e
+		}
+	}
+
+	return l, nil
+}
+
+// String is "name:uuid:level", with "-" for a missing uuid or level.
+func (this InstanceWorldId) String() string {
+	r := string(this.Name) + ":"
+
+	if u, ok := this.Uuid.Get(); ok {
+		r += u.String()
+	} else {
+		r += "-"
+	}
+
+	r += ":"
+
+	if f, ok := this.Level.Get(); ok {
+		r += strconv.FormatFloat(float64(f), 'g', -1, 32)
+	} else {
+		r += "-"
+	}
+
+	return r
+}
+
+func parseInstanceWorldId(s string) (InstanceWorldId, error) {
+	r := InstanceWorldId{}
+
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 || parts[0] == "" {
+		return r, fmt.Errorf("invalid instance world %q", s)
+	}
+
+	r.Name = String(parts[0])
+
+	if len(parts) > 1 && parts[1] != "-" {
+		u, e := ParseUUID(parts[1])
+		if e != nil {
+			return r, e
+		}
+		r.Uuid = Some[UUID, *UUID](u)
+	}
+
+	if len(parts) > 2 && parts[2] != "-" {
+		f, e := strconv.ParseFloat(parts[2], 32)
+		if e != nil {
+			return r, fmt.Errorf("invalid instance level %q", parts[2])
+		}
+		r.Level = Some[Float32, *Float32](Float32(f))
+	}
+
+	return r, nil
+}
+
+// WorldId is where a warp leads: a world of the universe, the ship of a
+// player, an instance, or nowhere. Only the field of its kind is used.
+//
+// On the wire, it is the kind as a byte, then that field. As a string, and in
+// json, it is the kind then the field, e.g. "CelestialWorld:x:y:z:planet",
+// "ClientShipWorld:uuid" or "InstanceWorld:name:uuid:level", or "Nowhere".
+type WorldId struct {
+	Kind      WorldKind
+	Celestial CelestialCoordinate
+	Ship      UUID
+	Instance  InstanceWorldId
+}
+
+// content is the field of the kind, nil for NoWorld.
+func (this *WorldId) content() (Serializer, error) {
+	switch this.Kind {
+	case NoWorld:
+		return nil, nil
+	case CelestialWorld:
+		return &this.Celestial, nil
+	case ClientShipWorld:
+		return &this.Ship, nil
+	case InstanceWorld:
+		return &this.Instance, nil
+	}
+
+	return nil, fmt.Errorf("unknown world kind %d", this.Kind)
+}
+
+func (this *WorldId) Read(rd io.Reader, endian byteorder.ByteOrder) error {
+	k, e := byteorder.Uint8(rd)
+	if e != nil {
+		return e
+	}
+
+	r := WorldId{Kind: WorldKind(k)}
+
+	c, e := r.content()
+	if e != nil {
+		return e
+	}
+
+	if c != nil {
+		if e := c.Read(rd, endian); e != nil {
+			return e
+		}
+	}
+
+	*this = r
+	return nil
+}
+
+func (this *WorldId) ReadBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	if len(buf) < 1 {
+		return 0, errShortRead
+	}
+
+	r := WorldId{Kind: WorldKind(buf[0])}
+
+	c, e := r.content()
+	if e != nil {
+		return 1, e
+	}
+
+	l := 1
+	if c != nil {
+		n, e := c.ReadBuf(buf[1:], endian)
+		l += n
+		if e != nil {
+			return l, e
+		}
+	}
+
+	*this = r
+	return l, nil
+}
+
+func (this *WorldId) Write(wt io.Writer, endian byteorder.ByteOrder) error {
+	c, e := this.content()
+	if e != nil {
+		return e
+	}
+
+	if e := byteorder.PutUint8(wt, uint8(this.Kind)); e != nil || c == nil {
+		return e
+	}
+
+	return c.Write(wt, endian)
+}
+
+func (this *WorldId) WriteBuf(buf []byte, endian byteorder.ByteOrder) (int, error) {
+	c, e := this.content()
+	if e != nil {
+		return 0, e
+	}
+
+	if len(buf) < 1 {
+		return 0, errShortWrite
+	}
+
+	buf[0] = uint8(this.Kind)
+	if c == nil {
+		return 1, nil
+	}
+
+	l, e := c.WriteBuf(buf[1:], endian)
+	return l + 1, e
+}
+
+func (this WorldId) String() string {
+	switch this.Kind {
+	case CelestialWorld:
+		return "CelestialWorld:" + this.Celestial.String()
+	case ClientShipWorld:
+		return "ClientShipWorld:" + this.Ship.String()
+	case InstanceWorld:
+		return "InstanceWorld:" + this.Instance.String()
+	}
+
+	return this.Kind.String()
+}
+
+// ParseWorldId reads what String returns. An empty string is NoWorld.
+func ParseWorldId(s string) (WorldId, error) {
+	r := WorldId{}
+
+	if s == "" || s == "Nowhere" {
+		return r, nil
+	}
+
+	kind, rest, ok := strings.Cut(s, ":")
+	if !ok {
+		return r, fmt.Errorf("invalid world id %q", s)
+	}
+
+	var e error
+
+	switch kind {
+	case "CelestialWorld":
+		r.Kind = CelestialWorld
+		r.Celestial, e = ParseCelestialCoordinate(rest)
+	case "ClientShipWorld":
+		r.Kind = ClientShipWorld
+		r.Ship, e = ParseUUID(rest)
+	case "InstanceWorld":
+		r.Kind = InstanceWorld
+		r.Instance, e = parseInstanceWorldId(rest)
+	default:
+		return r, fmt.Errorf("unknown world kind %q", kind)
+	}
+
+	if e != nil {
+		return WorldId{}, e
+	}
+
+	return r, nil
+}
+
+// MarshalJSON writes the string, or null for NoWorld.
+func (this WorldId) MarshalJSON() ([]byte, error) {
+	if this.Kind == NoWorld {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(this.String())
+}
+
+func (this *WorldId) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		*this = WorldId{}
+		return nil
+	}
+
+	var s string
+	if e := json.Unmarshal(data, &s); e != nil {
+		return e
+	}
+
+	r, e := ParseWorldId(s)
+	if e != nil {
+		return e
+	}
+
+	*this = r
+	return nil
+}
@@ -0,0 +1,139 @@
+package data_types
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCelestialCoordinate(t *testing.T) {
+	c := CelestialCoordinate{Location: Vec3I{-12, 34, 5}, Planet: 2, Satellite: 1}
+	roundTrip(t, c, []byte{
+		0xff, 0xff, 0xff, 0xf4, 0, 0, 0, 34, 0, 0, 0, 5,
+		0, 0, 0, 2, 0, 0, 0, 1,
+	})
+
+	for s, want := range map[string]CelestialCoordinate{
+		"":                   {},
+		"null":               {},
+		"-12:34:5":           {Location: Vec3I{-12, 34, 5}},
+		"-12:34:5:2":         {Location: Vec3I{-12, 34, 5}, Planet: 2},
+		"-12:34:5:2:1":       c,
+		"-12_34_5_2_1.world": c,
+		"-12 34 5 2 1":       c,
+		"100000000:-1:0:10":  {Location: Vec3I{100000000, -1, 0}, Planet: 10},
+	} {
+		got, e := ParseCelestialCoordinate(s)
+		if e != nil || got != want {
+			t.Fatalf("%q: got %+v %v, want %+v", s, got, e, want)
+		}
+	}
+
+	for _, s := range []string{"1:2", "1:2:3:4:5:6", "1:2:x", "1:2:9999999999"} {
+		if _, e := ParseCelestialCoordinate(s); e == nil {
+			t.Fatalf("%q: parsed", s)
+		}
+	}
+
+	if s := c.String(); s != "-12:34:5:2:1" {
+		t.Fatalf("got %s", s)
+	}
+
+	if s := c.Filename(); s != "-12_34_5_2_1.world" {
+		t.Fatalf("got %s", s)
+	}
+
+	if !c.IsSatellite() || c.IsPlanetaryBody() || c.IsSystem() || c.IsNull() {
+		t.Fatalf("%s: wrong kind", c)
+	}
+
+	b, e := json.Marshal([]CelestialCoordinate{c, {}})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if string(b) != `[{"location":[-12,34,5],"planet":2,"satellite":1},null]` {
+		t.Fatalf("got %s", b)
+	}
+
+	var r []CelestialCoordinate
+	if e := json.Unmarshal([]byte(`[{"location":[-12,34,5],"planet":2,"satellite":1},null,"-12:34:5:2:1"]`), &r); e != nil {
+		t.Fatal(e)
+	}
+
+	if !reflect.DeepEqual(r, []CelestialCoordinate{c, {}, c}) {
+		t.Fatalf("got %+v", r)
+	}
+}
+
+func TestWorldId(t *testing.T) {
+	u := UUID{0xde, 0xad, 0xbe, 0xef, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+	ids := map[string]WorldId{
+		"Nowhere": {},
+		"CelestialWorld:1:2:3:4": {
+			Kind:      CelestialWorld,
+			Celestial: CelestialCoordinate{Location: Vec3I{1, 2, 3}, Planet: 4},
+		},
+		"ClientShipWorld:deadbeef0405060708090a0b0c0d0e0f": {
+			Kind: ClientShipWorld,
+			Ship: u,
+		},
+		"InstanceWorld:outpost:-:-": {
+			Kind:     InstanceWorld,
+			Instance: InstanceWorldId{Name: "outpost"},
+		},
+		"InstanceWorld:mission:deadbeef0405060708090a0b0c0d0e0f:2.5": {
+			Kind: InstanceWorld,
+			Instance: InstanceWorldId{
+				Name:  "mission",
+				Uuid:  Some[UUID, *UUID](u),
+				Level: Some[Float32, *Float32](2.5),
+			},
+		},
+	}
+
+	for s, want := range ids {
+		roundTrip(t, want, nil)
+
+		if got := want.String(); got != s {
+			t.Fatalf("got %s, want %s", got, s)
+		}
+
+		got, e := ParseWorldId(s)
+		if e != nil || !reflect.DeepEqual(got, want) {
+			t.Fatalf("%q: got %+v %v", s, got, e)
+		}
+	}
+
+	roundTrip(t, ids["InstanceWorld:outpost:-:-"], []byte{3, 7, 'o', 'u', 't', 'p', 'o', 's', 't', 0, 0})
+
+	got, e := ParseWorldId("InstanceWorld:outpost")
+	if e != nil || !reflect.DeepEqual(got, ids["InstanceWorld:outpost:-:-"]) {
+		t.Fatalf("got %+v %v", got, e)
+	}
+
+	for _, s := range []string{"Somewhere:1", "CelestialWorld", "ClientShipWorld:dead", "InstanceWorld::-:-", "InstanceWorld:a:-:x"} {
+		if _, e := ParseWorldId(s); e == nil {
+			t.Fatalf("%q: parsed", s)
+		}
+	}
+
+	b, e := json.Marshal([]WorldId{ids["CelestialWorld:1:2:3:4"], {}})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if string(b) != `["CelestialWorld:1:2:3:4",null]` {
+		t.Fatalf("got %s", b)
+	}
+
+	var r []WorldId
+	if e := json.Unmarshal(b, &r); e != nil {
+		t.Fatal(e)
+	}
+
+	if !reflect.DeepEqual(r, []WorldId{ids["CelestialWorld:1:2:3:4"], {}}) {
+		t.Fatalf("got %+v", r)
+	}
+}
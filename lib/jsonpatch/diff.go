@@ -0,0 +1,285 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	. "github.com/xhebox/sbutils/lib/data_types"
+	"github.com/xhebox/sbutils/lib/sbvj01"
+)
+
+func escape(k String) string {
+	return strings.Replace(strings.Replace(string(k), "~", "~0", -1), "/", "~1", -1)
+}
+
+func objectOf(v interface{}) (sbvj01.Object, bool) {
+	switch n := v.(type) {
+	case sbvj01.Object:
+		return n, true
+	case map[string]interface{}:
+		r := make(sbvj01.Object, 0, len(n))
+		for k, v := range n {
+			r = append(r, sbvj01.Pair{Key: String(k), Value: v})
+		}
+		sort.Slice(r, func(i, j int) bool { return r[i].Key < r[j].Key })
+		return r, true
+	case map[String]interface{}:
+		r := make(sbvj01.Object, 0, len(n))
+		for k, v := range n {
+			r = append(r, sbvj01.Pair{Key: k, Value: v})
+		}
+		sort.Slice(r, func(i, j int) bool { return r[i].Key < r[j].Key })
+		return r, true
+	}
+
+	return nil, false
+}
+
+// Same compares values by their wire type, like sbvj01.Equal: a varint 1 is
+// not the same as a number 1. Object key order does not matter.
+func Same(a, b interface{}) bool {
+	return sbvj01.Equal(a, b)
+}
+
+// Diff returns the operations that turn a into b. Arrays are compared
+// element by element, and grow or shrink at their end.
+func Diff(a, b interface{}) []Operation {
+	return diff("", a, b, []Operation{})
+}
+
+func diff(path string, a, b interface{}, ops []Operation) []Operation {
+	if Same(a, b) {
+		return ops
+	}
+
+	if x, ok := objectOf(a); ok {
+		if y, ok := objectOf(b); ok {
+			for _, p := range x {
+				v, ok := y.Get(p.Key)
+				if !ok {
+					ops = append(ops, Operation{Op: "remove", Path: path + "/" + escape(p.Key)})
+					continue
+				}
+
+				ops = diff(path+"/"+escape(p.Key), p.Value, v, ops)
+			}
+
+			for _, p := range y {
+				if _, ok := x.Get(p.Key); !ok {
+					ops = append(ops, Operation{Op: "add", Path: path + "/" + escape(p.Key), Value: p.Value, HasValue: true})
+				}
+			}
+
+			return ops
+		}
+	}
+
+	if x, ok := a.([]interface{}); ok {
+		if y, ok := b.([]interface{}); ok {
+			n := len(x)
+			if len(y) < n {
+				n = len(y)
+			}
+
+			for k := 0; k < n; k++ {
+				ops = diff(path+"/"+strconv.Itoa(k), x[k], y[k], ops)
+			}
+
+			for k := len(x) - 1; k >= n; k-- {
+				ops = append(ops, Operation{Op: "remove", Path: path + "/" + strconv.Itoa(k)})
+			}
+
+			for k := n; k < len(y); k++ {
+				ops = append(ops, Operation{Op: "add", Path: path + "/-", Value: y[k], HasValue: true})
+			}
+
+			return ops
+		}
+	}
+
+	return append(ops, Operation{Op: "replace", Path: path, Value: b, HasValue: true})
+}
+
+// Conflict is a value changed differently on both sides of a three-way merge.
+// A Has field is false where the key is missing.
+type Conflict struct {
+	Path      string
+	Base      interface{}
+	Ours      interface{}
+	Theirs    interface{}
+	HasBase   bool
+	HasOurs   bool
+	HasTheirs bool
+}
+
+// Marker is what a conflict is replaced with in the merged document, so that
+// it shows in json and can be resolved by hand:
+//
+//	{"____conflict____": {"ours": ..., "base": ..., "theirs": ...}}
+//
+// A missing side is left out.
+func (c Conflict) Marker() sbvj01.Object {
+	r := sbvj01.Object{}
+
+	if c.HasOurs {
+		r = append(r, sbvj01.Pair{Key: "ours", Value: c.Ours})
+	}
+
+	if c.HasBase {
+		r = append(r, sbvj01.Pair{Key: "base", Value: c.Base})
+	}
+
+	if c.HasTheirs {
+		r = append(r, sbvj01.Pair{Key: "theirs", Value: c.Theirs})
+	}
+
+	return sbvj01.Object{{Key: ConflictKey, Value: r}}
+}
+
+const ConflictKey = "____conflict____"
+
+type side struct {
+	v   interface{}
+	has bool
+}
+
+func (s side) same(o side) bool {
+	return s.has == o.has && (!s.has || Same(s.v, o.v))
+}
+
+// Merge3 merges the changes from base to theirs into ours. Objects merge key
+// by key, and arrays element by element when no side changed their length.
+// Every conflict is listed, and replaced by its Marker in the result.
+func Merge3(base, ours, theirs interface{}) (interface{}, []Conflict) {
+	conflicts := []Conflict{}
+	r, _ := merge3("", side{base, true}, side{ours, true}, side{theirs, true}, &conflicts)
+	return r.v, conflicts
+}
+
+func merge3(path string, base, ours, theirs side, conflicts *[]Conflict) (side, bool) {
+	switch {
+	case ours.same(theirs), base.same(theirs):
+		return ours, true
+	case base.same(ours):
+		return theirs, true
+	}
+
+	if ours.has && theirs.has {
+		o, ook := objectOf(ours.v)
+		t, tok := objectOf(theirs.v)
+		b, bok := objectOf(base.v)
+		if !base.has {
+			b, bok = sbvj01.Object{}, true
+		}
+
+		if ook && tok && bok {
+			r := sbvj01.Object{}
+
+			keys := make([]String, 0, len(o)+len(t))
+			for _, p := range o {
+				keys = append(keys, p.Key)
+			}
+			for _, p := range t {
+				if _, ok := o.Get(p.Key); !ok {
+					keys = append(keys, p.Key)
+				}
+			}
+
+			for _, k := range keys {
+				var bs, os, ts side
+				bs.v, bs.has = b.Get(k)
+				os.v, os.has = o.Get(k)
+				ts.v, ts.has = t.Get(k)
+
+				m, _ := merge3(path+"/"+escape(k), bs, os, ts, conflicts)
+				if m.has {
+					r = append(r, sbvj01.Pair{Key: k, Value: m.v})
+				}
+			}
+
+			return side{r, true}, true
+		}
+
+		o2, ook := ours.v.([]interface{})
+		t2, tok := theirs.v.([]interface{})
+		b2, bok := base.v.([]interface{})
+
+		if ook && tok && bok && len(o2) == len(b2) && len(t2) == len(b2) {
+			r := make([]interface{}, len(b2))
+
+			for k := range r {
+				m, _ := merge3(path+"/"+strconv.Itoa(k), side{b2[k], true}, side{o2[k], true}, side{t2[k], true}, conflicts)
+				r[k] = m.v
+			}
+
+			return side{r, true}, true
+		}
+	}
+
+	c := Conflict{
+		Path:      path,
+		Base:      base.v,
+		Ours:      ours.v,
+		Theirs:    theirs.v,
+		HasBase:   base.has,
+		HasOurs:   ours.has,
+		HasTheirs: theirs.has,
+	}
+	*conflicts = append(*conflicts, c)
+
+	return side{c.Marker(), true}, false
+}
+
+// typed converts a value for a patch file, so that sbvj01 types survive
+// Patch.Normalize: numbers always have a fraction or an exponent, which
+// varints never have.
+func typed(v interface{}) interface{} {
+	switch n := v.(type) {
+	case float64:
+		s := strconv.FormatFloat(n, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return json.Number(s)
+	case []interface{}:
+		r := make([]interface{}, len(n))
+		for k := range n {
+			r[k] = typed(n[k])
+		}
+		return r
+	}
+
+	if o, ok := objectOf(v); ok {
+		r := make(sbvj01.Object, len(o))
+		for k := range o {
+			r[k] = sbvj01.Pair{Key: o[k].Key, Value: typed(o[k].Value)}
+		}
+		return r
+	}
+
+	return v
+}
+
+// MarshalJSON writes an operation as in a patch file.
+func (op Operation) MarshalJSON() ([]byte, error) {
+	r := sbvj01.Object{
+		{Key: "op", Value: op.Op},
+		{Key: "path", Value: op.Path},
+	}
+
+	if op.From != "" || op.Op == "move" || op.Op == "copy" {
+		r = append(r, sbvj01.Pair{Key: "from", Value: op.From})
+	}
+
+	if op.HasValue {
+		r = append(r, sbvj01.Pair{Key: "value", Value: typed(op.Value)})
+	}
+
+	if op.Inverse {
+		r = append(r, sbvj01.Pair{Key: "inverse", Value: true})
+	}
+
+	return json.Marshal(r)
+}
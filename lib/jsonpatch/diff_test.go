@@ -0,0 +1,107 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	. "github.com/xhebox/sbutils/lib/data_types"
+	"github.com/xhebox/sbutils/lib/sbvj01"
+)
+
+func lit(t *testing.T, s string) interface{} {
+	v, e := sbvj01.ParseLiteral(s)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return v
+}
+
+func TestDiff(t *testing.T) {
+	for _, c := range [][2]interface{}{
+		{lit(t, `{"a":1,"b":[1,2,3],"c":{"x":"y"},"d/e":true}`), lit(t, `{"a":1,"b":[1,5],"c":{"x":"z","n":null},"f~":[]}`)},
+		{lit(t, `[1,2]`), lit(t, `[1,2,{"a":[3.5]},4]`)},
+		{lit(t, `{"n":1}`), lit(t, `{"n":1.0}`)},
+		{lit(t, `{"n":1.5}`), lit(t, `{"n":"1.5"}`)},
+		{lit(t, `{"o":{}}`), lit(t, `{"o":[]}`)},
+		{lit(t, `1`), lit(t, `{"whole":"document"}`)},
+	} {
+		a, b := c[0], c[1]
+
+		ops := Diff(a, b)
+
+		js, e := json.Marshal(ops)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		p, e := Read(bytes.NewReader(js))
+		if e != nil {
+			t.Fatalf("%s: %+v", js, e)
+		}
+		p.Normalize()
+
+		r, _, e := p.Apply(a)
+		if e != nil {
+			t.Fatalf("%s: %+v", js, e)
+		}
+
+		if !Same(r, b) {
+			t.Fatalf("%s: got %s, want %s", js, dumpValue(t, r), dumpValue(t, b))
+		}
+
+		if len(Diff(b, b)) != 0 {
+			t.Fatal("a value differs from itself")
+		}
+	}
+
+	if ops := Diff(lit(t, `{"n":1}`), lit(t, `{"n":1.0}`)); len(ops) != 1 || ops[0].Op != "replace" {
+		t.Fatalf("varint and float are the same: %+v", ops)
+	}
+
+	js, _ := json.Marshal(Diff(lit(t, `{"n":1}`), lit(t, `{"n":2.0}`)))
+	if string(js) != `[{"op":"replace","path":"/n","value":2.0}]` {
+		t.Fatalf("got %s", js)
+	}
+}
+
+func dumpValue(t *testing.T, v interface{}) string {
+	b, e := json.Marshal(v)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return string(b)
+}
+
+func TestMerge3(t *testing.T) {
+	base := lit(t, `{"name":"hero","level":1,"bag":["a","b"],"gone":1,"ship":{"fuel":10,"crew":[]}}`)
+	ours := lit(t, `{"name":"hero","level":2,"bag":["a","c"],"ship":{"fuel":10,"crew":["x"]},"mine":true}`)
+	theirs := lit(t, `{"name":"hero2","level":3,"bag":["a","b"],"gone":1,"ship":{"fuel":5,"crew":[]},"yours":true}`)
+
+	r, conflicts := Merge3(base, ours, theirs)
+
+	want := `{"name":"hero2","level":{"____conflict____":{"ours":2,"base":1,"theirs":3}},"bag":["a","c"],"ship":{"fuel":5,"crew":["x"]},"mine":true,"yours":true}`
+	if got := dumpValue(t, r); got != want {
+		t.Fatalf("got %s\nwant %s", got, want)
+	}
+
+	if len(conflicts) != 1 || conflicts[0].Path != "/level" {
+		t.Fatalf("conflicts %+v", conflicts)
+	}
+
+	// deleted on one side and changed on the other
+	_, conflicts = Merge3(lit(t, `{"a":1}`), lit(t, `{}`), lit(t, `{"a":2}`))
+	if len(conflicts) != 1 || conflicts[0].HasOurs || !conflicts[0].HasTheirs {
+		t.Fatalf("conflicts %+v", conflicts)
+	}
+
+	// the same change on both sides, and a type-only change
+	r, conflicts = Merge3(lit(t, `{"a":1,"b":1}`), lit(t, `{"a":2,"b":1}`), lit(t, `{"a":2,"b":1.0}`))
+	if len(conflicts) != 0 {
+		t.Fatalf("conflicts %+v", conflicts)
+	}
+
+	if b, _ := r.(sbvj01.Object).Get(String("b")); b != float64(1) {
+		t.Fatalf("b is %#v", b)
+	}
+}
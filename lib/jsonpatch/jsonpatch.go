@@ -0,0 +1,293 @@
+// Package jsonpatch applies json patches the way starbound does to its
+// assets: RFC 6902 operations, where test may be inverted or only check that
+// a path exists, grouped into batches that are dropped as a whole when one of
+// their tests fails.
+//
+// Documents are values as returned by sbvj01.Read or sbvj01.DecodeJSON, and
+// plain maps are accepted as objects.
+package jsonpatch
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+	. "github.com/xhebox/sbutils/lib/data_types"
+	"github.com/xhebox/sbutils/lib/sbvj01"
+)
+
+type Operation struct {
+	Op   string
+	Path string
+	From string
+	// HasValue tells a missing value from a null one, which matters to test
+	Value    interface{}
+	HasValue bool
+	Inverse  bool
+}
+
+// Patch is a list of batches. A patch file made of operations is a single
+// batch, while one made of arrays of operations has a batch per array.
+type Patch [][]Operation
+
+// TestError is returned by ApplyBatch when a test operation fails.
+type TestError struct {
+	Op Operation
+}
+
+func (e *TestError) Error() string {
+	if e.Op.Inverse {
+		return "inverse test failed at " + strconv.Quote(e.Op.Path)
+	}
+
+	return "test failed at " + strconv.Quote(e.Op.Path)
+}
+
+func IsTestFailed(e error) bool {
+	_, ok := errors.Cause(e).(*TestError)
+	return ok
+}
+
+func str(o sbvj01.Object, key String) (string, bool, error) {
+	v, ok := o.Get(key)
+	if !ok {
+		return "", false, nil
+	}
+
+	switch s := v.(type) {
+	case string:
+		return s, true, nil
+	case String:
+		return string(s), true, nil
+	}
+
+	return "", true, errors.Errorf("expect %s to be a string, got %T", key, v)
+}
+
+func parseOperation(v interface{}) (Operation, error) {
+	r := Operation{}
+
+	o, ok := v.(sbvj01.Object)
+	if !ok {
+		return r, errors.Errorf("expect an operation object, got %T", v)
+	}
+
+	var has bool
+	var e error
+
+	if r.Op, has, e = str(o, "op"); e != nil {
+		return r, e
+	} else if !has {
+		return r, errors.New("missing op")
+	}
+
+	if r.Path, has, e = str(o, "path"); e != nil {
+		return r, e
+	} else if !has {
+		return r, errors.Errorf("%s: missing path", r.Op)
+	}
+
+	if r.From, has, e = str(o, "from"); e != nil {
+		return r, e
+	} else if !has && (r.Op == "move" || r.Op == "copy") {
+		return r, errors.Errorf("%s: missing from", r.Op)
+	}
+
+	r.Value, r.HasValue = o.Get("value")
+
+	switch r.Op {
+	case "add", "replace":
+		if !r.HasValue {
+			return r, errors.Errorf("%s: missing value", r.Op)
+		}
+	case "remove", "move", "copy", "test":
+	default:
+		return r, errors.Errorf("unknown op %q", r.Op)
+	}
+
+	if inverse, ok := o.Get("inverse"); ok {
+		b, ok := inverse.(bool)
+		if !ok {
+			return r, errors.Errorf("expect inverse to be a bool, got %T", inverse)
+		}
+		r.Inverse = b
+	}
+
+	return r, nil
+}
+
+func parseBatch(v interface{}) ([]Operation, error) {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("expect an array of operations, got %T", v)
+	}
+
+	r := make([]Operation, 0, len(arr))
+
+	for k := range arr {
+		op, e := parseOperation(arr[k])
+		if e != nil {
+			return nil, errors.Wrapf(e, "operation %d", k)
+		}
+
+		r = append(r, op)
+	}
+
+	return r, nil
+}
+
+// Parse converts a patch decoded by sbvj01.DecodeJSON.
+func Parse(v interface{}) (Patch, error) {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("expect an array, got %T", v)
+	}
+
+	if len(arr) == 0 {
+		return Patch{}, nil
+	}
+
+	if _, ok := arr[0].([]interface{}); !ok {
+		batch, e := parseBatch(arr)
+		if e != nil {
+			return nil, e
+		}
+
+		return Patch{batch}, nil
+	}
+
+	r := make(Patch, 0, len(arr))
+
+	for k := range arr {
+		batch, e := parseBatch(arr[k])
+		if e != nil {
+			return nil, errors.Wrapf(e, "batch %d", k)
+		}
+
+		r = append(r, batch)
+	}
+
+	return r, nil
+}
+
+// Read decodes a patch file and parses it. The name Decode would clash with
+// data_types.Decode, which this package dot-imports.
+func Read(rd io.Reader) (Patch, error) {
+	v, e := sbvj01.DecodeJSON(rd)
+	if e != nil {
+		return nil, e
+	}
+
+	return Parse(v)
+}
+
+// Normalize converts the values of p to the types sbvj01.Read returns, so
+// that what is added to a versioned json gets varints for integers and
+// floats for other numbers.
+func (p Patch) Normalize() {
+	for _, batch := range p {
+		for k := range batch {
+			batch[k].Value = sbvj01.Normalize(batch[k].Value)
+		}
+	}
+}
+
+type Result struct {
+	Applied int
+	Skipped int // batches dropped by a failed test
+}
+
+// Apply applies each batch in turn. A batch whose test fails is dropped and
+// counted as skipped, while any other failure aborts the whole patch.
+func (p Patch) Apply(doc interface{}) (interface{}, Result, error) {
+	r := Result{}
+
+	for k, batch := range p {
+		res, e := ApplyBatch(doc, batch)
+		if e != nil {
+			if IsTestFailed(e) {
+				r.Skipped++
+				continue
+			}
+
+			return doc, r, errors.Wrapf(e, "batch %d", k)
+		}
+
+		doc = res
+		r.Applied++
+	}
+
+	return doc, r, nil
+}
+
+// ApplyBatch applies the operations to a copy of doc, so that doc is left
+// untouched on failure. A failed test returns a *TestError.
+func ApplyBatch(doc interface{}, ops []Operation) (interface{}, error) {
+	doc = sbvj01.Copy(doc)
+
+	for k, op := range ops {
+		var e error
+
+		doc, e = apply(doc, op)
+		if e != nil {
+			return nil, errors.Wrapf(e, "operation %d, %s %s", k, op.Op, op.Path)
+		}
+	}
+
+	return doc, nil
+}
+
+func apply(doc interface{}, op Operation) (interface{}, error) {
+	path, e := parsePointer(op.Path)
+	if e != nil {
+		return nil, e
+	}
+
+	switch op.Op {
+	case "add":
+		return add(doc, path, sbvj01.Copy(op.Value))
+	case "remove":
+		doc, _, e = remove(doc, path)
+		return doc, e
+	case "replace":
+		return replace(doc, path, sbvj01.Copy(op.Value))
+	case "move", "copy":
+		from, e := parsePointer(op.From)
+		if e != nil {
+			return nil, e
+		}
+
+		var v interface{}
+		if op.Op == "move" {
+			if isPrefix(from, path) && len(from) != len(path) {
+				return nil, errors.Errorf("can not move %q into itself", op.From)
+			}
+
+			doc, v, e = remove(doc, from)
+		} else {
+			v, e = get(doc, from)
+			v = sbvj01.Copy(v)
+		}
+
+		if e != nil {
+			return nil, e
+		}
+
+		return add(doc, path, v)
+	case "test":
+		v, e := get(doc, path)
+
+		ok := e == nil
+		if ok && op.HasValue {
+			ok = sbvj01.Equivalent(v, op.Value)
+		}
+
+		if ok == op.Inverse {
+			return nil, &TestError{Op: op}
+		}
+
+		return doc, nil
+	}
+
+	return nil, errors.Errorf("unknown op %q", op.Op)
+}
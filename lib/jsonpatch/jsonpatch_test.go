@@ -0,0 +1,175 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/xhebox/sbutils/lib/sbvj01"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	v, e := sbvj01.DecodeJSON(strings.NewReader(s))
+	if e != nil {
+		t.Fatal(e)
+	}
+	return v
+}
+
+func run(t *testing.T, doc, patch string) (string, Result, error) {
+	p, e := Read(strings.NewReader(patch))
+	if e != nil {
+		t.Fatalf("%s: %+v", patch, e)
+	}
+
+	r, res, e := p.Apply(decode(t, doc))
+	if e != nil {
+		return "", res, e
+	}
+
+	b, e := json.Marshal(r)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	return string(b), res, nil
+}
+
+func TestOperations(t *testing.T) {
+	for _, c := range []struct {
+		doc, patch, want string
+	}{
+		{`{"a":1}`, `[{"op":"add","path":"/b","value":[1]}]`, `{"a":1,"b":[1]}`},
+		{`{"a":[1,2]}`, `[{"op":"add","path":"/a/1","value":3}]`, `{"a":[1,3,2]}`},
+		{`{"a":[1,2]}`, `[{"op":"add","path":"/a/-","value":3}]`, `{"a":[1,2,3]}`},
+		{`{"a":1,"b":2}`, `[{"op":"add","path":"/a","value":3}]`, `{"a":3,"b":2}`},
+		{`{"a":1,"b":2}`, `[{"op":"remove","path":"/a"}]`, `{"b":2}`},
+		{`{"a":[1,2,3]}`, `[{"op":"remove","path":"/a/1"}]`, `{"a":[1,3]}`},
+		{`{"a":1,"b":2}`, `[{"op":"replace","path":"/a","value":null}]`, `{"a":null,"b":2}`},
+		{`{"a":{"x":1},"b":2}`, `[{"op":"move","from":"/a/x","path":"/b"}]`, `{"a":{},"b":1}`},
+		{`{"a":{"x":1}}`, `[{"op":"copy","from":"/a","path":"/b"},{"op":"add","path":"/b/y","value":2}]`, `{"a":{"x":1},"b":{"x":1,"y":2}}`},
+		{`{"a/b":1,"m~n":2}`, `[{"op":"remove","path":"/a~1b"},{"op":"remove","path":"/m~0n"}]`, `{}`},
+		{`{"a":1}`, `[{"op":"replace","path":"","value":[]}]`, `[]`},
+		// numbers compare by value, objects regardless of order
+		{`{"a":1,"o":{"x":1,"y":[2]}}`, `[{"op":"test","path":"/a","value":1},{"op":"test","path":"/o","value":{"y":[2],"x":1}},{"op":"add","path":"/ok","value":true}]`, `{"a":1,"o":{"x":1,"y":[2]},"ok":true}`},
+	} {
+		got, _, e := run(t, c.doc, c.patch)
+		if e != nil {
+			t.Fatalf("%s: %+v", c.patch, e)
+		}
+
+		if got != c.want {
+			t.Errorf("%s: got %s, want %s", c.patch, got, c.want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, patch := range []string{
+		`[{"op":"remove","path":"/nope"}]`,
+		`[{"op":"replace","path":"/nope","value":1}]`,
+		`[{"op":"add","path":"/a/5","value":1}]`,
+		`[{"op":"add","path":"/a/01","value":1}]`,
+		`[{"op":"add","path":"/s/x","value":1}]`,
+		`[{"op":"move","from":"/o","path":"/o/x"}]`,
+		`[{"op":"remove","path":""}]`,
+	} {
+		if _, _, e := run(t, `{"a":[1],"s":"str","o":{}}`, patch); e == nil || IsTestFailed(e) {
+			t.Errorf("%s: got %v", patch, e)
+		}
+	}
+
+	// test compares numbers by value like the game, but large integers exactly
+	for patch, skipped := range map[string]int{
+		`[{"op":"test","path":"/n","value":1.0}]`:                  0,
+		`[{"op":"test","path":"/n","value":1.5}]`:                  1,
+		`[{"op":"test","path":"/big","value":9007199254740992.0}]`: 0,
+		`[{"op":"test","path":"/big","value":9007199254740993}]`:   1,
+		`[{"op":"test","path":"/n","value":"1"}]`:                  1,
+	} {
+		if _, res, e := run(t, `{"n":1,"big":9007199254740992}`, patch); e != nil || res.Skipped != skipped {
+			t.Errorf("%s: got %+v %v", patch, res, e)
+		}
+	}
+
+	for _, patch := range []string{
+		`{}`,
+		`[{"op":"nope","path":""}]`,
+		`[{"op":"add","path":"/a"}]`,
+		`[{"op":"copy","path":"/a"}]`,
+		`[[{"op":"remove","path":"/a"}], {"op":"remove","path":"/a"}]`,
+	} {
+		if _, e := Read(strings.NewReader(patch)); e == nil {
+			t.Errorf("%s: no error", patch)
+		}
+	}
+}
+
+func TestBatches(t *testing.T) {
+	doc := `{"species":"human","items":[]}`
+
+	patch := `[
+		[
+			{"op":"test","path":"/species","value":"apex"},
+			{"op":"add","path":"/items/-","value":"banana"}
+		],
+		[
+			{"op":"add","path":"/items/-","value":"partial"},
+			{"op":"test","path":"/tail"}
+		],
+		[
+			{"op":"test","path":"/tail","inverse":true},
+			{"op":"add","path":"/tail","value":"none"}
+		],
+		[
+			{"op":"test","path":"/species","value":"human","inverse":true},
+			{"op":"remove","path":"/species"}
+		],
+		[
+			{"op":"test","path":"/species","value":"human"},
+			{"op":"add","path":"/items/0","value":"hat"}
+		]
+	]`
+
+	got, res, e := run(t, doc, patch)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	want := `{"species":"human","items":["hat"],"tail":"none"}`
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	if res != (Result{Applied: 2, Skipped: 3}) {
+		t.Fatalf("result %+v", res)
+	}
+
+	// a flat patch is one batch, dropped as a whole
+	got, res, e = run(t, doc, `[{"op":"add","path":"/x","value":1},{"op":"test","path":"/x","value":2}]`)
+	if e != nil || got != doc || res.Skipped != 1 {
+		t.Fatalf("got %s %+v %v", got, res, e)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	p, e := Read(strings.NewReader(`[{"op":"add","path":"/a","value":[1,1.5,"s"]}]`))
+	if e != nil {
+		t.Fatal(e)
+	}
+	p.Normalize()
+
+	r, _, e := p.Apply(sbvj01.Object{})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	a, _ := r.(sbvj01.Object).Get("a")
+	arr := a.([]interface{})
+	if _, ok := arr[0].(int64); !ok {
+		t.Errorf("integer became %T", arr[0])
+	}
+	if _, ok := arr[1].(float64); !ok {
+		t.Errorf("float became %T", arr[1])
+	}
+}
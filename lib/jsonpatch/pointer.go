@@ -0,0 +1,231 @@
+package jsonpatch
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	. "github.com/xhebox/sbutils/lib/data_types"
+	"github.com/xhebox/sbutils/lib/sbvj01"
+)
+
+// parsePointer splits a RFC 6901 json pointer into unescaped tokens.
+func parsePointer(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(s, "/") {
+		return nil, errors.Errorf("pointer %q does not start with /", s)
+	}
+
+	r := strings.Split(s[1:], "/")
+	for k := range r {
+		r[k] = strings.Replace(strings.Replace(r[k], "~1", "/", -1), "~0", "~", -1)
+	}
+
+	return r, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+
+	for k := range prefix {
+		if prefix[k] != path[k] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// index parses an array index, which is valid up to max.
+func index(tok string, max int) (int, error) {
+	if tok == "" || len(tok) > 1 && tok[0] == '0' {
+		return 0, errors.Errorf("bad array index %q", tok)
+	}
+
+	i, e := strconv.Atoi(tok)
+	if e != nil || i < 0 {
+		return 0, errors.Errorf("bad array index %q", tok)
+	}
+
+	if i > max {
+		return 0, errors.Errorf("array index %d out of range", i)
+	}
+
+	return i, nil
+}
+
+func child(v interface{}, tok string) (interface{}, error) {
+	switch n := v.(type) {
+	case sbvj01.Object:
+		r, ok := n.Get(String(tok))
+		if !ok {
+			return nil, errors.Errorf("no key %q", tok)
+		}
+		return r, nil
+	case map[string]interface{}:
+		r, ok := n[tok]
+		if !ok {
+			return nil, errors.Errorf("no key %q", tok)
+		}
+		return r, nil
+	case map[String]interface{}:
+		r, ok := n[String(tok)]
+		if !ok {
+			return nil, errors.Errorf("no key %q", tok)
+		}
+		return r, nil
+	case []interface{}:
+		i, e := index(tok, len(n)-1)
+		if e != nil {
+			return nil, e
+		}
+		return n[i], nil
+	}
+
+	return nil, errors.Errorf("can not look up %q in %T", tok, v)
+}
+
+func isObject(v interface{}) bool {
+	switch v.(type) {
+	case sbvj01.Object, map[string]interface{}, map[String]interface{}:
+		return true
+	}
+
+	return false
+}
+
+func setChild(v interface{}, tok string, c interface{}) interface{} {
+	switch n := v.(type) {
+	case sbvj01.Object:
+		n.Set(String(tok), c)
+		return n
+	case map[string]interface{}:
+		n[tok] = c
+	case map[String]interface{}:
+		n[String(tok)] = c
+	case []interface{}:
+		i, _ := strconv.Atoi(tok)
+		n[i] = c
+	}
+
+	return v
+}
+
+func get(v interface{}, path []string) (interface{}, error) {
+	for _, tok := range path {
+		var e error
+
+		v, e = child(v, tok)
+		if e != nil {
+			return nil, e
+		}
+	}
+
+	return v, nil
+}
+
+type parentFunc func(parent interface{}, tok string) (interface{}, interface{}, error)
+
+// modify calls fn on the parent of path, which it replaces by the first
+// value fn returns, and returns the second one.
+func modify(v interface{}, path []string, fn parentFunc) (interface{}, interface{}, error) {
+	if len(path) == 1 {
+		return fn(v, path[0])
+	}
+
+	c, e := child(v, path[0])
+	if e != nil {
+		return nil, nil, e
+	}
+
+	c, r, e := modify(c, path[1:], fn)
+	if e != nil {
+		return nil, nil, e
+	}
+
+	return setChild(v, path[0], c), r, nil
+}
+
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	doc, _, e := modify(doc, path, func(parent interface{}, tok string) (interface{}, interface{}, error) {
+		arr, ok := parent.([]interface{})
+		if !ok {
+			if !isObject(parent) {
+				return nil, nil, errors.Errorf("can not add %q to %T", tok, parent)
+			}
+
+			return setChild(parent, tok, value), nil, nil
+		}
+
+		if tok == "-" {
+			return append(arr, value), nil, nil
+		}
+
+		i, e := index(tok, len(arr))
+		if e != nil {
+			return nil, nil, e
+		}
+
+		arr = append(arr, nil)
+		copy(arr[i+1:], arr[i:])
+		arr[i] = value
+
+		return arr, nil, nil
+	})
+
+	return doc, e
+}
+
+// replace keeps the value where it was, even in an object.
+func replace(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	doc, _, e := modify(doc, path, func(parent interface{}, tok string) (interface{}, interface{}, error) {
+		if _, e := child(parent, tok); e != nil {
+			return nil, nil, e
+		}
+
+		return setChild(parent, tok, value), nil, nil
+	})
+
+	return doc, e
+}
+
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("can not remove the whole document")
+	}
+
+	return modify(doc, path, func(parent interface{}, tok string) (interface{}, interface{}, error) {
+		r, e := child(parent, tok)
+		if e != nil {
+			return nil, nil, e
+		}
+
+		switch n := parent.(type) {
+		case sbvj01.Object:
+			n.Delete(String(tok))
+			return n, r, nil
+		case map[string]interface{}:
+			delete(n, tok)
+		case map[String]interface{}:
+			delete(n, String(tok))
+		case []interface{}:
+			i, _ := strconv.Atoi(tok)
+			return append(n[:i], n[i+1:]...), r, nil
+		}
+
+		return parent, r, nil
+	})
+}
@@ -2,7 +2,7 @@ package packet
 
 import (
 	"github.com/xhebox/bstruct/byteorder"
-	. "github.com/xhebox/sbutils/lib/common"
+	. "github.com/xhebox/sbutils/lib/data_types"
 )
 
 type ConnectFailurePacket struct {
@@ -10,19 +10,15 @@ type ConnectFailurePacket struct {
 }
 
 func (r *ConnectFailurePacket) Unpack(e byteorder.ByteOrder, buf []byte) (err error) {
-	_, err = r.Execuse.ReadBuf(buf, e)
+	_, err = DecodeBuf(buf, e, r)
 	return
 }
 
 func (r *ConnectFailurePacket) Pack(e byteorder.ByteOrder) (*BasePacket, error) {
-	c := &BasePacket{
-		Type: ConnectFailure,
-		Buf:  make([]byte, len(r.Execuse)+byteorder.VMAXLEN),
+	buf, err := EncodeBytes(e, r)
+	if err != nil {
+		return nil, err
 	}
 
-	if _, e := r.Execuse.WriteBuf(c.Buf, e); e != nil {
-		return nil, e
-	}
-
-	return c, nil
+	return &BasePacket{Type: ConnectFailure, Buf: buf}, nil
 }
@@ -4,11 +4,16 @@ import (
 	"bytes"
 	"compress/zlib"
 	"io"
+	"math"
 
 	"github.com/pkg/errors"
 	"github.com/xhebox/bstruct/byteorder"
+	"github.com/xhebox/sbutils/lib/data_types"
 )
 
+// ReadPacket reads a packet, inflating it if it is compressed. With a limit
+// set by data_types.LimitLength on rd, a longer packet, before or after
+// inflating, fails with a *data_types.ErrTooLarge.
 func ReadPacket(rd io.Reader, end byteorder.ByteOrder) (*BasePacket, error) {
 	pktType, e := byteorder.Uint8(rd)
 	if e != nil {
@@ -20,29 +25,43 @@ func ReadPacket(rd io.Reader, end byteorder.ByteOrder) (*BasePacket, error) {
 		return nil, errors.WithStack(e)
 	}
 
-	if length > 0 {
-		buf := make([]byte, length)
-
-		if _, e := io.ReadFull(rd, buf); e != nil {
+	if length >= 0 {
+		buf, e := data_types.ReadLength(rd, uint64(length))
+		if e != nil {
 			return nil, e
 		}
 
 		return &BasePacket{Type: pktType, Buf: buf}, nil
 	}
 
+	// the limit applies to the compressed length, then to the inflated data
+	max := data_types.MaxLength(rd)
+	if e := data_types.CheckLength(rd, uint64(-length)); e != nil {
+		return nil, e
+	}
+
 	zin, e := zlib.NewReader(io.LimitReader(rd, -length))
 	if e != nil {
 		return nil, errors.WithStack(e)
 	}
 	defer zin.Close()
 
+	var src io.Reader = zin
+	if max != 0 && max < math.MaxInt64 {
+		src = io.LimitReader(zin, int64(max)+1)
+	}
+
 	buffer := &bytes.Buffer{}
 
-	_, e = buffer.ReadFrom(zin)
+	_, e = buffer.ReadFrom(src)
 	if e != nil {
 		return nil, errors.WithStack(e)
 	}
 
+	if max != 0 && uint64(buffer.Len()) > max {
+		return nil, &data_types.ErrTooLarge{Length: uint64(buffer.Len()), Max: max}
+	}
+
 	return &BasePacket{Type: pktType, Buf: buffer.Bytes()}, nil
 }
 
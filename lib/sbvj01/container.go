@@ -0,0 +1,258 @@
+package sbvj01
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/xhebox/bstruct/byteorder"
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+// Container is the layout of a versioned json file.
+type Container int
+
+const (
+	Auto    Container = iota // VJMagic if the file starts with Magic, VJ otherwise
+	VJMagic                  // Magic, header, body
+	VJ                       // header, body
+	Raw                      // body only
+	NVJ                      // count, then count times header, body
+)
+
+var containerNames = map[Container]string{
+	Auto:    "auto",
+	VJMagic: "vjmagic",
+	VJ:      "vj",
+	Raw:     "raw",
+	NVJ:     "nvj",
+}
+
+func (c Container) String() string {
+	if s, ok := containerNames[c]; ok {
+		return s
+	}
+
+	return "unknown"
+}
+
+func ParseContainer(s string) (Container, error) {
+	for c, name := range containerNames {
+		if name == s {
+			return c, nil
+		}
+	}
+
+	return Auto, errors.Errorf("unknown container %q", s)
+}
+
+// Document is one versioned json. Header is zero in a Raw container.
+type Document struct {
+	Header VerJsonHdr  `json:"hdr"`
+	Body   interface{} `json:"body"`
+}
+
+// DecodeDocument converts a {"hdr": ..., "body": ...} object, as decoded by
+// DecodeJSON, back into a Document. Other keys are ignored.
+func DecodeDocument(v interface{}) (Document, error) {
+	r := Document{}
+
+	obj, ok := v.(Object)
+	if !ok {
+		return r, errors.Errorf("expect an object, got %T", v)
+	}
+
+	hdr, ok := obj.Get("hdr")
+	if !ok {
+		return r, errors.New("missing hdr")
+	}
+
+	h, ok := hdr.(Object)
+	if !ok {
+		return r, errors.Errorf("expect hdr to be an object, got %T", hdr)
+	}
+
+	if id, ok := h.Get("id"); ok {
+		s, ok := id.(string)
+		if !ok {
+			return r, errors.Errorf("expect id to be a string, got %T", id)
+		}
+		r.Header.Id = String(s)
+	}
+
+	if versioned, ok := h.Get("versioned"); ok {
+		b, ok := versioned.(bool)
+		if !ok {
+			return r, errors.Errorf("expect versioned to be a bool, got %T", versioned)
+		}
+		r.Header.Versioned = b
+	}
+
+	if version, ok := h.Get("version"); ok {
+		n, ok := version.(json.Number)
+		if !ok {
+			return r, errors.Errorf("expect version to be a number, got %T", version)
+		}
+
+		i, e := n.Int64()
+		if e != nil {
+			return r, e
+		}
+		r.Header.Version = int32(i)
+	}
+
+	r.Body, _ = obj.Get("body")
+
+	return r, nil
+}
+
+// ReadFile reads every document of a container, and returns the container
+// actually read, which only differs from c for Auto. Raw and the vj layouts
+// hold exactly one document.
+func ReadFile(rd io.Reader, c Container) ([]Document, Container, error) {
+	return readFile(rd, c, ReadHdr, Read)
+}
+
+// ReadFileTagged is ReadFile with bodies read by ReadTagged.
+func ReadFileTagged(rd io.Reader, c Container) ([]Document, Container, error) {
+	return readFile(rd, c, ReadHdr, ReadTagged)
+}
+
+// detect resolves Auto by peeking at the start of rd, and returns the reader
+// to go on with.
+func detect(rd io.Reader, c Container) (io.Reader, Container) {
+	if c != Auto {
+		return rd, c
+	}
+
+	b := bufio.NewReader(rd)
+
+	c = VJ
+	if m, _ := b.Peek(len(Magic)); bytes.Equal(m, Magic) {
+		c = VJMagic
+	}
+
+	if max := MaxLength(rd); max != 0 {
+		return LimitLength(b, max), c
+	}
+
+	return b, c
+}
+
+func readFile(rd io.Reader, c Container, readHdr func(io.Reader) (VerJsonHdr, error), read func(io.Reader) (interface{}, error)) ([]Document, Container, error) {
+	rd, c = detect(rd, c)
+
+	readDoc := func(hdr bool) (Document, error) {
+		r := Document{}
+
+		var e error
+		if hdr {
+			r.Header, e = readHdr(rd)
+			if e != nil {
+				return r, errors.Wrapf(e, "failed to read the header")
+			}
+		}
+
+		r.Body, e = read(rd)
+		if e != nil {
+			return r, errors.Wrapf(e, "failed to read the body")
+		}
+
+		return r, nil
+	}
+
+	switch c {
+	case VJMagic:
+		m := make([]byte, len(Magic))
+		if _, e := io.ReadFull(rd, m); e != nil {
+			return nil, c, errors.Wrapf(e, "failed to read the magic")
+		}
+
+		if !bytes.Equal(m, Magic) {
+			return nil, c, errors.Errorf("bad magic %q", m)
+		}
+
+		fallthrough
+	case VJ, Raw:
+		r, e := readDoc(c != Raw)
+		if e != nil {
+			return nil, c, e
+		}
+
+		return []Document{r}, c, nil
+	case NVJ:
+		cnt, e := byteorder.UVarint(rd, byteorder.BigEndian)
+		if e != nil {
+			return nil, c, errors.Wrapf(e, "failed to read the count")
+		}
+
+		docs := []Document{}
+
+		for i := uint64(0); i < cnt; i++ {
+			r, e := readDoc(true)
+			if e != nil {
+				return nil, c, errors.Wrapf(e, "document %d", i)
+			}
+
+			docs = append(docs, r)
+		}
+
+		return docs, c, nil
+	default:
+		return nil, c, errors.Errorf("unknown container %d", c)
+	}
+}
+
+// WriteFile writes docs in the container c, which can not be Auto. Raw and
+// the vj layouts take exactly one document.
+func WriteFile(wt io.Writer, c Container, docs ...Document) error {
+	return writeFile(wt, c, docs, Write)
+}
+
+// WriteFileTagged is WriteFile with bodies written by WriteTagged.
+func WriteFileTagged(wt io.Writer, c Container, docs ...Document) error {
+	return writeFile(wt, c, docs, WriteTagged)
+}
+
+func writeFile(wt io.Writer, c Container, docs []Document, write func(io.Writer, interface{}) error) error {
+	switch c {
+	case VJMagic, VJ, Raw:
+		if len(docs) != 1 {
+			return errors.Errorf("%s holds one document, got %d", c, len(docs))
+		}
+
+		if c == VJMagic {
+			if _, e := wt.Write(Magic); e != nil {
+				return e
+			}
+		}
+
+		if c != Raw {
+			if e := WriteHdr(wt, docs[0].Header); e != nil {
+				return e
+			}
+		}
+
+		return write(wt, docs[0].Body)
+	case NVJ:
+		if e := byteorder.PutUVarint(wt, byteorder.BigEndian, uint64(len(docs))); e != nil {
+			return e
+		}
+
+		for k := range docs {
+			if e := WriteHdr(wt, docs[k].Header); e != nil {
+				return e
+			}
+
+			if e := write(wt, docs[k].Body); e != nil {
+				return e
+			}
+		}
+
+		return nil
+	default:
+		return errors.Errorf("can not write container %s", c)
+	}
+}
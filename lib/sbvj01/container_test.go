@@ -0,0 +1,79 @@
+package sbvj01
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestContainer(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+
+	docs := []Document{
+		{Header: VerJsonHdr{Id: "PlayerEntity", Versioned: true, Version: 30}, Body: randomValue(r, 4)},
+		{Header: VerJsonHdr{Id: "Item"}, Body: randomValue(r, 4)},
+	}
+
+	for _, c := range []Container{VJMagic, VJ, Raw, NVJ} {
+		in := docs[:1]
+		if c == NVJ {
+			in = docs
+		}
+
+		buf := &bytes.Buffer{}
+		if e := WriteFile(buf, c, in...); e != nil {
+			t.Fatalf("%s: %+v", c, e)
+		}
+		raw := buf.Bytes()
+
+		detect := c
+		if c == VJMagic || c == VJ {
+			detect = Auto
+		}
+
+		out, got, e := ReadFile(bytes.NewReader(raw), detect)
+		if e != nil {
+			t.Fatalf("%s: %+v", c, e)
+		}
+
+		if got != c {
+			t.Fatalf("%s: read as %s", c, got)
+		}
+
+		if c == Raw {
+			in = []Document{{Body: in[0].Body}}
+		}
+
+		if !reflect.DeepEqual(in, out) {
+			t.Fatalf("%s: documents differ\n%+v\n%+v", c, in, out)
+		}
+
+		// the json layout written by dumpsbvj01 is read back by makesbvj01
+		if c != Raw {
+			js, e := json.Marshal(out[0])
+			if e != nil {
+				t.Fatal(e)
+			}
+
+			v, e := DecodeJSON(bytes.NewReader(js))
+			if e != nil {
+				t.Fatal(e)
+			}
+
+			doc, e := DecodeDocument(v)
+			if e != nil {
+				t.Fatal(e)
+			}
+
+			if doc.Header != out[0].Header {
+				t.Fatalf("%s: header %+v, want %+v", c, doc.Header, out[0].Header)
+			}
+		}
+	}
+
+	if e := WriteFile(&bytes.Buffer{}, VJ, docs...); e == nil {
+		t.Fatal("vj accepted two documents")
+	}
+}
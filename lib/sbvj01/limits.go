@@ -0,0 +1,280 @@
+package sbvj01
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+	"github.com/xhebox/bstruct/byteorder"
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+// DecodeOptions bounds what a decoder accepts, for input that can not be
+// trusted. A zero field means no limit.
+type DecodeOptions struct {
+	MaxDepth    int   // nesting of arrays and objects
+	MaxElements int64 // elements of all arrays and objects together
+	MaxString   int64 // bytes of one string or object key
+	MaxBytes    int64 // bytes read in total
+}
+
+// SafeDecodeOptions are generous for real saves, but keep a crafted file from
+// exhausting memory or the stack.
+var SafeDecodeOptions = DecodeOptions{
+	MaxDepth:    256,
+	MaxElements: 1 << 24,
+	MaxString:   1 << 24,
+	MaxBytes:    1 << 28,
+}
+
+type Limit byte
+
+const (
+	DepthLimit Limit = iota + 1
+	ElementLimit
+	StringLimit
+	ByteLimit
+)
+
+func (l Limit) String() string {
+	switch l {
+	case DepthLimit:
+		return "depth"
+	case ElementLimit:
+		return "element"
+	case StringLimit:
+		return "string"
+	case ByteLimit:
+		return "byte"
+	}
+
+	return "unknown"
+}
+
+// LimitError is returned when the input exceeds a limit of DecodeOptions.
+// Offset is where the value that exceeds it starts.
+type LimitError struct {
+	Limit  Limit
+	Max    int64
+	Offset int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s limit %d exceeded at byte %d", e.Limit, e.Max, e.Offset)
+}
+
+// limitReader counts the bytes read, and refuses to read past max. It passes
+// the length limit of the reader it wraps along.
+type limitReader struct {
+	rd     io.Reader
+	off    int64
+	max    int64
+	length uint64
+}
+
+func (l *limitReader) MaxLength() uint64 {
+	return l.length
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.max > 0 {
+		if l.off >= l.max && len(p) != 0 {
+			return 0, &LimitError{Limit: ByteLimit, Max: l.max, Offset: l.off}
+		}
+
+		if int64(len(p)) > l.max-l.off {
+			p = p[:l.max-l.off]
+		}
+	}
+
+	n, e := l.rd.Read(p)
+	l.off += int64(n)
+	return n, e
+}
+
+type reader struct {
+	rd    *limitReader
+	opts  DecodeOptions
+	depth int
+	elems int64
+}
+
+func newReader(rd io.Reader, opts DecodeOptions) *reader {
+	return &reader{rd: &limitReader{rd: rd, max: opts.MaxBytes, length: MaxLength(rd)}, opts: opts}
+}
+
+// Read decodes one value like Read, within the limits of o.
+func (o DecodeOptions) Read(rd io.Reader) (interface{}, error) {
+	return newReader(rd, o).value()
+}
+
+// ReadFile is ReadFile within the limits of o. They apply to the whole file,
+// headers included, and offsets count from its start.
+func (o DecodeOptions) ReadFile(rd io.Reader, c Container) ([]Document, Container, error) {
+	// detect first, so the buffering of Auto is not counted as read
+	rd, c = detect(rd, c)
+	d := newReader(rd, o)
+
+	return readFile(d.rd, c, func(io.Reader) (VerJsonHdr, error) {
+		return d.header()
+	}, func(io.Reader) (interface{}, error) {
+		return d.value()
+	})
+}
+
+// header is ReadHdr with the id read as a bounded string.
+func (d *reader) header() (r VerJsonHdr, e error) {
+	r.Id, e = d.string()
+	if e != nil {
+		return
+	}
+
+	r.Versioned, e = byteorder.Bool(d.rd)
+	if e != nil {
+		return
+	}
+
+	if r.Versioned {
+		r.Version, e = byteorder.Int32(d.rd, byteorder.BigEndian)
+		if e != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (d *reader) limit(l Limit, max int64, off int64) error {
+	return &LimitError{Limit: l, Max: max, Offset: off}
+}
+
+func (d *reader) string() (String, error) {
+	off := d.rd.off
+
+	n, e := byteorder.UVarint(d.rd, byteorder.BigEndian)
+	if e != nil {
+		return "", e
+	}
+
+	if d.opts.MaxString > 0 && n > uint64(d.opts.MaxString) {
+		return "", d.limit(StringLimit, d.opts.MaxString, off)
+	}
+
+	if d.opts.MaxBytes > 0 && n > uint64(d.opts.MaxBytes-d.rd.off) {
+		return "", d.limit(ByteLimit, d.opts.MaxBytes, off)
+	}
+
+	b, e := ReadLength(d.rd, n)
+	if e != nil {
+		return "", e
+	}
+
+	return String(b), nil
+}
+
+// count reads the element count of a container starting at off.
+func (d *reader) count(off int64) (int, error) {
+	if d.opts.MaxDepth > 0 && d.depth >= d.opts.MaxDepth {
+		return 0, d.limit(DepthLimit, int64(d.opts.MaxDepth), off)
+	}
+
+	cnt, e := byteorder.UVarint(d.rd, byteorder.BigEndian)
+	if e != nil {
+		return 0, e
+	}
+
+	if e := CheckLength(d.rd, cnt); e != nil {
+		return 0, e
+	}
+
+	if d.opts.MaxElements > 0 && cnt > uint64(d.opts.MaxElements-d.elems) {
+		return 0, d.limit(ElementLimit, d.opts.MaxElements, off)
+	}
+
+	if cnt > math.MaxInt32 {
+		return 0, errors.Errorf("count %d too large at byte %d", cnt, off)
+	}
+
+	d.elems += int64(cnt)
+	return int(cnt), nil
+}
+
+func (d *reader) value() (interface{}, error) {
+	off := d.rd.off
+
+	typ, e := byteorder.Uint8(d.rd)
+	if e != nil {
+		return nil, e
+	}
+
+	switch typ {
+	case NullT:
+		return nil, nil
+	case NumberT:
+		return byteorder.Float64(d.rd, byteorder.BigEndian)
+	case BoolT:
+		return byteorder.Bool(d.rd)
+	case VarintT:
+		return byteorder.Varint(d.rd, byteorder.BigEndian)
+	case StringT:
+		return d.string()
+	case ArrayT:
+		return d.array(off)
+	case ObjectT:
+		return d.object(off)
+	default:
+		return nil, errors.Errorf("unknown type %d at byte %d", typ, off)
+	}
+}
+
+func (d *reader) array(off int64) ([]interface{}, error) {
+	cnt, e := d.count(off)
+	if e != nil {
+		return nil, e
+	}
+
+	d.depth++
+	defer func() { d.depth-- }()
+
+	r := []interface{}{}
+
+	for i := 0; i < cnt; i++ {
+		value, e := d.value()
+		if e != nil {
+			return nil, e
+		}
+
+		r = append(r, value)
+	}
+
+	return r, nil
+}
+
+func (d *reader) object(off int64) (Object, error) {
+	cnt, e := d.count(off)
+	if e != nil {
+		return nil, e
+	}
+
+	d.depth++
+	defer func() { d.depth-- }()
+
+	r := Object{}
+
+	for i := 0; i < cnt; i++ {
+		key, e := d.string()
+		if e != nil {
+			return nil, e
+		}
+
+		value, e := d.value()
+		if e != nil {
+			return nil, e
+		}
+
+		r = append(r, Pair{Key: key, Value: value})
+	}
+
+	return r, nil
+}
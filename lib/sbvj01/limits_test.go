@@ -0,0 +1,155 @@
+package sbvj01
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/xhebox/bstruct/byteorder"
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+func limitHit(t *testing.T, data []byte, opts DecodeOptions, l Limit, off int64) {
+	_, e := opts.Read(bytes.NewReader(data))
+
+	le, ok := errors.Cause(e).(*LimitError)
+	if !ok {
+		t.Fatalf("%s: got %v", l, e)
+	}
+
+	if le.Limit != l || le.Offset != off {
+		t.Fatalf("%s: got %+v, want offset %d", l, le, off)
+	}
+}
+
+func TestDecodeLimits(t *testing.T) {
+	// [[[[...]]]] nested 1000 deep
+	deep := bytes.Repeat([]byte{ArrayT, 1}, 1000)
+	deep = append(deep, NullT)
+	limitHit(t, deep, DecodeOptions{MaxDepth: 10}, DepthLimit, 20)
+
+	if _, e := (DecodeOptions{MaxDepth: 1000}).Read(bytes.NewReader(deep)); e != nil {
+		t.Fatal(e)
+	}
+
+	// {"k": array claiming 1<<40 elements}
+	huge := bytes.NewBuffer([]byte{ObjectT, 1, 1, 'k', ArrayT})
+	byteorder.PutUVarint(huge, byteorder.BigEndian, 1<<40)
+	limitHit(t, huge.Bytes(), DecodeOptions{MaxElements: 1 << 20}, ElementLimit, 4)
+
+	str := &bytes.Buffer{}
+	str.Write([]byte{ArrayT, 2, NullT, StringT})
+	byteorder.PutUVarint(str, byteorder.BigEndian, 1<<30)
+	limitHit(t, str.Bytes(), DecodeOptions{MaxString: 1 << 20}, StringLimit, 4)
+	limitHit(t, str.Bytes(), DecodeOptions{MaxBytes: 1 << 20}, ByteLimit, 4)
+
+	r := rand.New(rand.NewSource(7))
+	buf := &bytes.Buffer{}
+	if e := Write(buf, randomValue(r, 4)); e != nil {
+		t.Fatal(e)
+	}
+
+	_, e := (DecodeOptions{MaxBytes: int64(buf.Len() - 1)}).Read(bytes.NewReader(buf.Bytes()))
+	if le, ok := errors.Cause(e).(*LimitError); !ok || le.Limit != ByteLimit {
+		t.Fatalf("got %v", e)
+	}
+
+	v, e := SafeDecodeOptions.Read(bytes.NewReader(buf.Bytes()))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	out := &bytes.Buffer{}
+	if e := Write(out, v); e != nil {
+		t.Fatal(e)
+	}
+
+	if !bytes.Equal(buf.Bytes(), out.Bytes()) {
+		t.Fatal("safe options changed the value")
+	}
+
+	file := append([]byte{}, Magic...)
+	file = append(file, 1, 'x', 0)
+	file = append(file, deep...)
+	fileHit := func(data []byte, c Container, opts DecodeOptions, l Limit, off int64) {
+		_, _, e := opts.ReadFile(bytes.NewReader(data), c)
+
+		le, ok := errors.Cause(e).(*LimitError)
+		if !ok {
+			t.Fatalf("file %s: got %v", l, e)
+		}
+
+		if le.Limit != l || le.Offset != off {
+			t.Fatalf("file %s: got %+v, want offset %d", l, le, off)
+		}
+	}
+
+	// offsets count from the start of the file, past the magic and header
+	fileHit(file, Auto, DecodeOptions{MaxDepth: 10}, DepthLimit, int64(len(Magic))+3+20)
+
+	// a header id is bounded like any other string
+	long := append([]byte{}, Magic...)
+	long = append(long, 100)
+	long = append(long, bytes.Repeat([]byte{'x'}, 100)...)
+	long = append(long, 0, NullT)
+	fileHit(long, Auto, DecodeOptions{MaxString: 10}, StringLimit, int64(len(Magic)))
+	fileHit(long, Auto, DecodeOptions{MaxBytes: 20}, ByteLimit, int64(len(Magic)))
+
+	// the byte budget is shared by every document of the file
+	nvj := []byte{3}
+	for i := 0; i < 3; i++ {
+		nvj = append(nvj, 1, 'x', 0, StringT, 4, 'a', 'b', 'c', 'd')
+	}
+
+	if _, _, e := (DecodeOptions{MaxBytes: int64(len(nvj))}).ReadFile(bytes.NewReader(nvj), NVJ); e != nil {
+		t.Fatal(e)
+	}
+
+	fileHit(nvj, NVJ, DecodeOptions{MaxBytes: 20}, ByteLimit, 1+9+9)
+}
+
+func TestLengthLimit(t *testing.T) {
+	tooLarge := func(what string, e error) {
+		te, ok := errors.Cause(e).(*ErrTooLarge)
+		if !ok || te.Length != 1<<30 {
+			t.Fatalf("%s: got %v", what, e)
+		}
+	}
+
+	limited := func(data []byte) io.Reader {
+		return LimitLength(bytes.NewReader(data), 1<<20)
+	}
+
+	str := &bytes.Buffer{}
+	str.Write([]byte{ArrayT, 2, NullT, StringT})
+	byteorder.PutUVarint(str, byteorder.BigEndian, 1<<30)
+
+	arr := &bytes.Buffer{}
+	arr.Write([]byte{ObjectT, 1, 1, 'k', ArrayT})
+	byteorder.PutUVarint(arr, byteorder.BigEndian, 1<<30)
+
+	for _, data := range [][]byte{str.Bytes(), arr.Bytes()} {
+		_, e := Read(limited(data))
+		tooLarge("read", e)
+
+		_, e = DecodeOptions{MaxDepth: 8}.Read(limited(data))
+		tooLarge("options", e)
+
+		_, e = ReadTagged(limited(data))
+		tooLarge("tagged", e)
+
+		d := NewDecoder(limited(data))
+		for e = nil; e == nil; {
+			_, e = d.Token()
+		}
+		tooLarge("token", e)
+	}
+
+	// a header id, behind the buffering of Auto
+	file := bytes.NewBuffer(append([]byte{}, Magic...))
+	byteorder.PutUVarint(file, byteorder.BigEndian, 1<<30)
+	_, _, e := ReadFile(limited(file.Bytes()), Auto)
+	tooLarge("file", e)
+}
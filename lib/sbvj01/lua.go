@@ -0,0 +1,309 @@
+package sbvj01
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+// LuaOptions changes how EncodeLua writes a value.
+type LuaOptions struct {
+	// Indent is repeated once per level, with one key or element per line.
+	// Empty means everything on one line.
+	Indent string
+
+	// SbMeta wraps the table in a function that passes it through
+	// sb.jsonMerge, to get the metatables the game needs, and renames the "1"
+	// keys around it like sbmeta.lua does, so that such tables are not turned
+	// into arrays. It only works in the game.
+	SbMeta bool
+
+	// Null is written for a null inside an array, where nil would end the
+	// array early, e.g. "false" or "{}". Empty means such a null is an error.
+	Null string
+}
+
+// sbMetaKey is what sbmeta.lua renames "1" keys to.
+const sbMetaKey = "xhedalaotqlwsl1"
+
+// sbMetaWrapper restores the renamed keys listed by their paths, deepest
+// first, after the merge. Paths go through the renamed keys, as they are
+// followed before the outer tables are restored.
+const sbMetaWrapper = `(function(t, paths)
+	t = sb.jsonMerge({}, t)
+	for i = #paths, 1, -1 do
+		local v = t
+		for _, k in ipairs(paths[i]) do
+			v = v[k]
+		end
+		v["1"] = v["` + sbMetaKey + `"]
+		v["` + sbMetaKey + `"] = nil
+	end
+	return t
+end)(`
+
+var luaKeywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true,
+	"end": true, "false": true, "for": true, "function": true, "goto": true,
+	"if": true, "in": true, "local": true, "nil": true, "not": true,
+	"or": true, "repeat": true, "return": true, "then": true, "true": true,
+	"until": true, "while": true,
+}
+
+type luaEncoder struct {
+	wt    *bufio.Writer
+	opts  LuaOptions
+	depth int
+	path  []interface{}
+	paths [][]interface{}
+}
+
+// EncodeLua writes v as a Lua 5.3 expression, as returned by Read or
+// DecodeJSON. Objects become tables with string keys, so a "1" key stays a
+// key, and arrays tables with integer keys from 1. Varints and integer json
+// numbers are written as integers, floats always with a fraction or an
+// exponent. A null is nil, which drops an object key; inside an array, it is
+// opts.Null instead.
+func EncodeLua(wt io.Writer, v interface{}, opts LuaOptions) error {
+	l := &luaEncoder{wt: bufio.NewWriter(wt), opts: opts}
+
+	if opts.SbMeta {
+		l.wt.WriteString(sbMetaWrapper)
+	}
+
+	if e := l.value(v); e != nil {
+		return e
+	}
+
+	if opts.SbMeta {
+		l.wt.WriteString(", {")
+		for k, p := range l.paths {
+			if k != 0 {
+				l.wt.WriteString(", ")
+			}
+
+			l.wt.WriteString("{")
+			for i, s := range p {
+				if i != 0 {
+					l.wt.WriteString(", ")
+				}
+
+				if n, ok := s.(int); ok {
+					l.wt.WriteString(strconv.Itoa(n))
+				} else {
+					l.str(s.(string))
+				}
+			}
+			l.wt.WriteString("}")
+		}
+		l.wt.WriteString("})")
+	}
+
+	return l.wt.Flush()
+}
+
+func (l *luaEncoder) str(s string) {
+	l.wt.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			l.wt.WriteString(`\"`)
+		case '\\':
+			l.wt.WriteString(`\\`)
+		case '\n':
+			l.wt.WriteString(`\n`)
+		case '\r':
+			l.wt.WriteString(`\r`)
+		case '\t':
+			l.wt.WriteString(`\t`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				// three digits, so that a following digit is not taken in
+				l.wt.WriteString(`\`)
+				l.wt.WriteString(strconv.Itoa(int(c) + 1000)[1:])
+			} else {
+				l.wt.WriteByte(c)
+			}
+		}
+	}
+	l.wt.WriteByte('"')
+}
+
+func (l *luaEncoder) float(f float64) {
+	switch {
+	case math.IsNaN(f):
+		l.wt.WriteString("(0/0)")
+	case math.IsInf(f, 1):
+		l.wt.WriteString("math.huge")
+	case math.IsInf(f, -1):
+		l.wt.WriteString("-math.huge")
+	default:
+		s := strconv.FormatFloat(f, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		l.wt.WriteString(s)
+	}
+}
+
+func (l *luaEncoder) integer(i int64) {
+	// -9223372036854775808 would be read as a float
+	if i == math.MinInt64 {
+		l.wt.WriteString("math.mininteger")
+		return
+	}
+
+	l.wt.WriteString(strconv.FormatInt(i, 10))
+}
+
+func (l *luaEncoder) newline() {
+	if l.opts.Indent == "" {
+		return
+	}
+
+	l.wt.WriteByte('\n')
+	for i := 0; i < l.depth; i++ {
+		l.wt.WriteString(l.opts.Indent)
+	}
+}
+
+func (l *luaEncoder) key(k string) {
+	ident := len(k) != 0 && !luaKeywords[k] && (k[0] < '0' || k[0] > '9')
+	for i := 0; ident && i < len(k); i++ {
+		c := k[i]
+		ident = c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+	}
+
+	if ident {
+		l.wt.WriteString(k)
+	} else {
+		l.wt.WriteByte('[')
+		l.str(k)
+		l.wt.WriteByte(']')
+	}
+
+	if l.opts.Indent == "" {
+		l.wt.WriteByte('=')
+	} else {
+		l.wt.WriteString(" = ")
+	}
+}
+
+func (l *luaEncoder) separator() {
+	l.wt.WriteByte(',')
+	if l.opts.Indent == "" {
+		l.wt.WriteByte(' ')
+	}
+}
+
+func (l *luaEncoder) value(v interface{}) error {
+	switch n := v.(type) {
+	case nil:
+		l.wt.WriteString("nil")
+	case bool:
+		l.wt.WriteString(strconv.FormatBool(n))
+	case int64:
+		l.integer(n)
+	case float64:
+		l.float(n)
+	case json.Number:
+		if i, e := n.Int64(); e == nil {
+			l.integer(i)
+		} else if f, e := n.Float64(); e == nil || math.IsInf(f, 0) {
+			// out of range is infinite, as the game reads it
+			l.float(f)
+		} else {
+			return errors.Wrapf(e, "bad number %s", n)
+		}
+	case String:
+		l.str(string(n))
+	case string:
+		switch n {
+		case "____NaN____":
+			l.float(math.NaN())
+		case "____+Inf____":
+			l.float(math.Inf(1))
+		case "____-Inf____":
+			l.float(math.Inf(-1))
+		default:
+			l.str(n)
+		}
+	case []interface{}:
+		l.wt.WriteByte('{')
+		l.depth++
+		for k := range n {
+			if k != 0 {
+				l.separator()
+			}
+			l.newline()
+
+			if n[k] == nil {
+				if l.opts.Null == "" {
+					return errors.Errorf("null at element %d of an array, which lua can not hold", k+1)
+				}
+
+				l.wt.WriteString(l.opts.Null)
+				continue
+			}
+
+			l.path = append(l.path, k+1)
+			e := l.value(n[k])
+			l.path = l.path[:len(l.path)-1]
+			if e != nil {
+				return e
+			}
+		}
+		l.depth--
+		if len(n) != 0 {
+			l.newline()
+		}
+		l.wt.WriteByte('}')
+	default:
+		o, ok := asObject(v)
+		if !ok {
+			return errors.Errorf("can not encode %T to lua", v)
+		}
+
+		if l.opts.SbMeta {
+			if _, ok := o.Get("1"); ok {
+				l.paths = append(l.paths, append([]interface{}{}, l.path...))
+			}
+		}
+
+		l.wt.WriteByte('{')
+		l.depth++
+		for k, p := range o {
+			if k != 0 {
+				l.separator()
+			}
+			l.newline()
+
+			name := string(p.Key)
+			if l.opts.SbMeta && name == "1" {
+				name = sbMetaKey
+			}
+
+			l.key(name)
+
+			l.path = append(l.path, name)
+			e := l.value(p.Value)
+			l.path = l.path[:len(l.path)-1]
+			if e != nil {
+				return e
+			}
+		}
+		l.depth--
+		if len(o) != 0 {
+			l.newline()
+		}
+		l.wt.WriteByte('}')
+	}
+
+	return nil
+}
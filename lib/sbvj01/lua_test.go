@@ -0,0 +1,97 @@
+package sbvj01
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+func TestEncodeLua(t *testing.T) {
+	lua := func(v interface{}, opts LuaOptions) string {
+		buf := &bytes.Buffer{}
+		if e := EncodeLua(buf, v, opts); e != nil {
+			t.Fatal(e)
+		}
+		return buf.String()
+	}
+
+	v, e := ParseLiteral(`{"1":2,"a b":[1,1.0,1e300,true],"end":"q\"\\\n\u0001x","_ok":{},"n":null}`)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	want := `{["1"]=2, ["a b"]={1, 1.0, 1e+300, true}, ["end"]="q\"\\\n\001x", _ok={}, n=nil}`
+	if got := lua(v, LuaOptions{}); got != want {
+		t.Fatalf("got %s\nwant %s", got, want)
+	}
+
+	// nil would end the array early
+	for s, want := range map[string]string{
+		"[1,null,3]": "{1, false, 3}",
+		"[1,null]":   "{1, false}",
+	} {
+		v, e := ParseLiteral(s)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		if e := EncodeLua(&bytes.Buffer{}, v, LuaOptions{}); e == nil {
+			t.Errorf("%s: no error without a placeholder", s)
+		}
+
+		if got := lua(v, LuaOptions{Null: "false"}); got != want {
+			t.Errorf("%s: got %s, want %s", s, got, want)
+		}
+	}
+
+	for v, want := range map[interface{}]string{
+		int64(math.MinInt64):        "math.mininteger",
+		json.Number("3"):            "3",
+		json.Number("3.5"):          "3.5",
+		json.Number("1e400"):        "math.huge",
+		"____NaN____":               "(0/0)",
+		"____-Inf____":              "-math.huge",
+		String("____NaN____"):       `"____NaN____"`,
+		String("\x7f1\xe4\xb8\xad"): "\"\\1271\xe4\xb8\xad\"",
+	} {
+		if got := lua(v, LuaOptions{}); got != want {
+			t.Errorf("%#v: got %s, want %s", v, got, want)
+		}
+	}
+
+	got := lua(Object{{Key: "a", Value: []interface{}{int64(1)}}, {Key: "b", Value: Object{}}}, LuaOptions{Indent: "\t"})
+	want = "{\n\ta = {\n\t\t1\n\t},\n\tb = {}\n}"
+	if got != want {
+		t.Fatalf("indented: got %q\nwant %q", got, want)
+	}
+
+	if e := EncodeLua(&bytes.Buffer{}, struct{}{}, LuaOptions{}); e == nil {
+		t.Fatal("expected an error for an unknown type")
+	}
+}
+
+func TestEncodeLuaSbMeta(t *testing.T) {
+	v, e := ParseLiteral(`{"1":{"1":"x","2":"y"},"l":[{"1":true}]}`)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	buf := &bytes.Buffer{}
+	if e := EncodeLua(buf, v, LuaOptions{SbMeta: true}); e != nil {
+		t.Fatal(e)
+	}
+	got := buf.String()
+
+	if !strings.HasPrefix(got, "(function(t, paths)") || !strings.Contains(got, "sb.jsonMerge({}, t)") {
+		t.Fatalf("no wrapper: %s", got)
+	}
+
+	want := `end)({xhedalaotqlwsl1={xhedalaotqlwsl1="x", ["2"]="y"}, l={{xhedalaotqlwsl1=true}}}, {{}, {"xhedalaotqlwsl1"}, {"l", 1}})`
+	if !strings.HasSuffix(got, want) {
+		t.Fatalf("got %s\nwant suffix %s", got, want)
+	}
+}
@@ -0,0 +1,682 @@
+package sbvj01
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/xhebox/bstruct/byteorder"
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+// Marshaler is implemented by types that encode themselves. MarshalSBVJ
+// returns one complete value, type byte included.
+type Marshaler interface {
+	MarshalSBVJ() ([]byte, error)
+}
+
+// Unmarshaler is implemented by types that decode themselves. The argument is
+// one complete value, type byte included.
+type Unmarshaler interface {
+	UnmarshalSBVJ([]byte) error
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	uuidType        = reflect.TypeOf(UUID{})
+	objectType      = reflect.TypeOf(Object{})
+	numberType      = reflect.TypeOf(json.Number(""))
+)
+
+type field struct {
+	name      string
+	index     []int
+	omitempty bool
+}
+
+var fieldCache sync.Map
+
+// fields lists the encoded fields of a struct type in declaration order.
+// Fields of untagged embedded structs are promoted, a shallower field hides
+// a deeper one of the same name, like encoding/json.
+func fields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+
+	r := []field{}
+	depth := map[string]int{}
+
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+
+			tag := sf.Tag.Get("sbvj")
+			if tag == "-" {
+				continue
+			}
+
+			name, opts := tag, ""
+			if i := strings.IndexByte(tag, ','); i != -1 {
+				name, opts = tag[:i], tag[i+1:]
+			}
+
+			idx := append(append([]int{}, index...), i)
+
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+
+			if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+				walk(ft, idx)
+				continue
+			}
+
+			if sf.PkgPath != "" {
+				continue
+			}
+
+			if name == "" {
+				name = sf.Name
+			}
+
+			if d, ok := depth[name]; ok && d <= len(idx) {
+				continue
+			}
+			depth[name] = len(idx)
+
+			for k := range r {
+				if r[k].name == name {
+					r = append(r[:k], r[k+1:]...)
+					break
+				}
+			}
+
+			r = append(r, field{
+				name:      name,
+				index:     idx,
+				omitempty: opts == "omitempty",
+			})
+		}
+	}
+	walk(t, nil)
+
+	fieldCache.Store(t, r)
+	return r
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex, except that nil embedded
+// pointers are reported as not found when !alloc, or allocated when alloc.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for k, i := range index {
+		if k > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// Marshal encodes v. Structs become objects keyed by field name, or by the
+// name in the `sbvj:"name,omitempty"` tag, integers become varints, floats
+// become numbers, and UUID becomes its hex string. Values built from
+// interface{}, Object and json.Number are encoded like Write does.
+func Marshal(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	if e := marshal(buf, reflect.ValueOf(v)); e != nil {
+		return nil, e
+	}
+
+	return buf.Bytes(), nil
+}
+
+func marshal(wt *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		return byteorder.PutUint8(wt, NullT)
+	}
+
+	t := v.Type()
+
+	if t.Implements(marshalerType) && !((v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil()) {
+		b, e := v.Interface().(Marshaler).MarshalSBVJ()
+		if e != nil {
+			return e
+		}
+		_, e = wt.Write(b)
+		return e
+	}
+
+	if v.CanAddr() && reflect.PtrTo(t).Implements(marshalerType) {
+		return marshal(wt, v.Addr())
+	}
+
+	switch t {
+	case uuidType:
+		u := v.Interface().(UUID)
+		s := String(hex.EncodeToString(u[:]))
+
+		if e := byteorder.PutUint8(wt, StringT); e != nil {
+			return e
+		}
+		return s.Write(wt, byteorder.BigEndian)
+	case objectType, numberType:
+		return Write(wt, v.Interface())
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return byteorder.PutUint8(wt, NullT)
+		}
+		return marshal(wt, v.Elem())
+	case reflect.Bool:
+		if e := byteorder.PutUint8(wt, BoolT); e != nil {
+			return e
+		}
+		return byteorder.PutBool(wt, v.Bool())
+	case reflect.Float32, reflect.Float64:
+		if e := byteorder.PutUint8(wt, NumberT); e != nil {
+			return e
+		}
+		return byteorder.PutFloat64(wt, byteorder.BigEndian, v.Float())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if e := byteorder.PutUint8(wt, VarintT); e != nil {
+			return e
+		}
+		return byteorder.PutVarint(wt, byteorder.BigEndian, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return errors.Errorf("%d overflows a varint", v.Uint())
+		}
+		if e := byteorder.PutUint8(wt, VarintT); e != nil {
+			return e
+		}
+		return byteorder.PutVarint(wt, byteorder.BigEndian, int64(v.Uint()))
+	case reflect.String:
+		s := String(v.String())
+
+		if e := byteorder.PutUint8(wt, StringT); e != nil {
+			return e
+		}
+		return s.Write(wt, byteorder.BigEndian)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return byteorder.PutUint8(wt, NullT)
+		}
+
+		if e := byteorder.PutUint8(wt, ArrayT); e != nil {
+			return e
+		}
+
+		if e := byteorder.PutUVarint(wt, byteorder.BigEndian, uint64(v.Len())); e != nil {
+			return e
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			if e := marshal(wt, v.Index(i)); e != nil {
+				return e
+			}
+		}
+
+		return nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return errors.Errorf("unsupported map key type %s", t.Key())
+		}
+
+		if v.IsNil() {
+			return byteorder.PutUint8(wt, NullT)
+		}
+
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+		if e := byteorder.PutUint8(wt, ObjectT); e != nil {
+			return e
+		}
+
+		if e := byteorder.PutUVarint(wt, byteorder.BigEndian, uint64(len(keys))); e != nil {
+			return e
+		}
+
+		for _, k := range keys {
+			s := String(k.String())
+			if e := s.Write(wt, byteorder.BigEndian); e != nil {
+				return e
+			}
+
+			if e := marshal(wt, v.MapIndex(k)); e != nil {
+				return e
+			}
+		}
+
+		return nil
+	case reflect.Struct:
+		type entry struct {
+			name  String
+			value reflect.Value
+		}
+
+		entries := []entry{}
+
+		for _, f := range fields(t) {
+			fv, ok := fieldByIndex(v, f.index, false)
+			if !ok || (f.omitempty && isEmpty(fv)) {
+				continue
+			}
+
+			entries = append(entries, entry{String(f.name), fv})
+		}
+
+		if e := byteorder.PutUint8(wt, ObjectT); e != nil {
+			return e
+		}
+
+		if e := byteorder.PutUVarint(wt, byteorder.BigEndian, uint64(len(entries))); e != nil {
+			return e
+		}
+
+		for k := range entries {
+			if e := entries[k].name.Write(wt, byteorder.BigEndian); e != nil {
+				return e
+			}
+
+			if e := marshal(wt, entries[k].value); e != nil {
+				return e
+			}
+		}
+
+		return nil
+	}
+
+	return errors.Errorf("unsupported type %s", t)
+}
+
+// Unmarshal decodes one value from data into v, which must be a non-nil
+// pointer. Varints and numbers convert to each other when the value fits,
+// object keys without a matching field are skipped, and interface{} targets
+// receive what Read returns.
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.Errorf("need a non-nil pointer, got %T", v)
+	}
+
+	d := &decoder{data: data, rd: bytes.NewReader(data)}
+
+	if e := d.unmarshal(rv.Elem()); e != nil {
+		return errors.Wrapf(e, "at byte %d", d.offset())
+	}
+
+	return nil
+}
+
+type decoder struct {
+	data []byte
+	rd   *bytes.Reader
+}
+
+func (d *decoder) offset() int {
+	return len(d.data) - d.rd.Len()
+}
+
+// skip moves over one value of type typ without decoding it.
+func (d *decoder) skip(typ byte) error {
+	switch typ {
+	case NullT:
+		return nil
+	case NumberT:
+		_, e := d.rd.Seek(8, io.SeekCurrent)
+		return e
+	case BoolT:
+		_, e := d.rd.ReadByte()
+		return e
+	case VarintT:
+		_, e := byteorder.UVarint(d.rd, byteorder.BigEndian)
+		return e
+	case StringT:
+		return d.skipString()
+	case ArrayT, ObjectT:
+		cnt, e := byteorder.UVarint(d.rd, byteorder.BigEndian)
+		if e != nil {
+			return e
+		}
+
+		for i := uint64(0); i < cnt; i++ {
+			if typ == ObjectT {
+				if e := d.skipString(); e != nil {
+					return e
+				}
+			}
+
+			t, e := d.rd.ReadByte()
+			if e != nil {
+				return e
+			}
+
+			if e := d.skip(t); e != nil {
+				return e
+			}
+		}
+
+		return nil
+	default:
+		return errors.Errorf("unknown type %d", typ)
+	}
+}
+
+func (d *decoder) skipString() error {
+	l, e := byteorder.UVarint(d.rd, byteorder.BigEndian)
+	if e != nil {
+		return e
+	}
+
+	if l > uint64(d.rd.Len()) {
+		return io.ErrUnexpectedEOF
+	}
+
+	_, e = d.rd.Seek(int64(l), io.SeekCurrent)
+	return e
+}
+
+func (d *decoder) unmarshal(v reflect.Value) error {
+	start := d.offset()
+
+	typ, e := d.rd.ReadByte()
+	if e != nil {
+		return e
+	}
+
+	if v.CanAddr() && reflect.PtrTo(v.Type()).Implements(unmarshalerType) {
+		if e := d.skip(typ); e != nil {
+			return e
+		}
+
+		return v.Addr().Interface().(Unmarshaler).UnmarshalSBVJ(d.data[start:d.offset()])
+	}
+
+	if typ == NullT {
+		switch v.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		if e := d.rd.UnreadByte(); e != nil {
+			return e
+		}
+
+		return d.unmarshal(v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return errors.Errorf("can not decode into %s", v.Type())
+		}
+
+		if e := d.rd.UnreadByte(); e != nil {
+			return e
+		}
+
+		r, e := Read(d.rd)
+		if e != nil {
+			return e
+		}
+
+		if r != nil {
+			v.Set(reflect.ValueOf(r))
+		} else {
+			v.Set(reflect.Zero(v.Type()))
+		}
+
+		return nil
+	}
+
+	if v.Type() == uuidType {
+		if typ != StringT {
+			return errors.Errorf("can not decode type %d into %s", typ, v.Type())
+		}
+
+		s, e := ReadString(d.rd, byteorder.BigEndian)
+		if e != nil {
+			return e
+		}
+
+		u, e := hex.DecodeString(string(s))
+		if e != nil || len(u) != len(UUID{}) {
+			return errors.Errorf("invalid uuid %q", s)
+		}
+
+		reflect.Copy(v, reflect.ValueOf(u))
+		return nil
+	}
+
+	if v.Type() == objectType {
+		if typ != ObjectT {
+			return errors.Errorf("can not decode type %d into %s", typ, v.Type())
+		}
+
+		r, e := ReadObject(d.rd)
+		if e != nil {
+			return e
+		}
+
+		v.Set(reflect.ValueOf(r))
+		return nil
+	}
+
+	switch typ {
+	case NumberT:
+		f, e := byteorder.Float64(d.rd, byteorder.BigEndian)
+		if e != nil {
+			return e
+		}
+
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(f)
+			return nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			// the range is checked before converting, which is undefined
+			// for a float out of the int64 range
+			if f == math.Trunc(f) && f >= -(1<<63) && f < 1<<63 && !v.OverflowInt(int64(f)) {
+				v.SetInt(int64(f))
+				return nil
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if f >= 0 && f == math.Trunc(f) && f < 1<<64 && !v.OverflowUint(uint64(f)) {
+				v.SetUint(uint64(f))
+				return nil
+			}
+		}
+
+		return errors.Errorf("can not decode number %v into %s", f, v.Type())
+	case VarintT:
+		n, e := byteorder.Varint(d.rd, byteorder.BigEndian)
+		if e != nil {
+			return e
+		}
+
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(float64(n))
+			return nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if !v.OverflowInt(n) {
+				v.SetInt(n)
+				return nil
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if n >= 0 && !v.OverflowUint(uint64(n)) {
+				v.SetUint(uint64(n))
+				return nil
+			}
+		}
+
+		return errors.Errorf("can not decode varint %d into %s", n, v.Type())
+	case BoolT:
+		b, e := byteorder.Bool(d.rd)
+		if e != nil {
+			return e
+		}
+
+		if v.Kind() != reflect.Bool {
+			return errors.Errorf("can not decode bool into %s", v.Type())
+		}
+
+		v.SetBool(b)
+		return nil
+	case StringT:
+		s, e := ReadString(d.rd, byteorder.BigEndian)
+		if e != nil {
+			return e
+		}
+
+		if v.Kind() != reflect.String {
+			return errors.Errorf("can not decode string into %s", v.Type())
+		}
+
+		v.SetString(string(s))
+		return nil
+	case ArrayT:
+		cnt, e := byteorder.UVarint(d.rd, byteorder.BigEndian)
+		if e != nil {
+			return e
+		}
+
+		// every element takes at least one byte
+		if cnt > uint64(d.rd.Len()) {
+			return io.ErrUnexpectedEOF
+		}
+
+		switch v.Kind() {
+		case reflect.Slice:
+			v.Set(reflect.MakeSlice(v.Type(), int(cnt), int(cnt)))
+		case reflect.Array:
+			if uint64(v.Len()) != cnt {
+				return errors.Errorf("can not decode %d elements into %s", cnt, v.Type())
+			}
+		default:
+			return errors.Errorf("can not decode array into %s", v.Type())
+		}
+
+		for i := 0; i < int(cnt); i++ {
+			if e := d.unmarshal(v.Index(i)); e != nil {
+				return e
+			}
+		}
+
+		return nil
+	case ObjectT:
+		cnt, e := byteorder.UVarint(d.rd, byteorder.BigEndian)
+		if e != nil {
+			return e
+		}
+
+		switch v.Kind() {
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return errors.Errorf("unsupported map key type %s", v.Type().Key())
+			}
+
+			if v.IsNil() {
+				v.Set(reflect.MakeMap(v.Type()))
+			}
+
+			for i := uint64(0); i < cnt; i++ {
+				key, e := ReadString(d.rd, byteorder.BigEndian)
+				if e != nil {
+					return e
+				}
+
+				elem := reflect.New(v.Type().Elem()).Elem()
+				if e := d.unmarshal(elem); e != nil {
+					return errors.Wrapf(e, "key %q", key)
+				}
+
+				v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+			}
+
+			return nil
+		case reflect.Struct:
+			fs := fields(v.Type())
+
+			for i := uint64(0); i < cnt; i++ {
+				key, e := ReadString(d.rd, byteorder.BigEndian)
+				if e != nil {
+					return e
+				}
+
+				var target reflect.Value
+				for k := range fs {
+					if fs[k].name == string(key) {
+						target, _ = fieldByIndex(v, fs[k].index, true)
+						break
+					}
+				}
+
+				if !target.IsValid() {
+					t, e := d.rd.ReadByte()
+					if e != nil {
+						return e
+					}
+
+					if e := d.skip(t); e != nil {
+						return e
+					}
+					continue
+				}
+
+				if e := d.unmarshal(target); e != nil {
+					return errors.Wrapf(e, "field %q", key)
+				}
+			}
+
+			return nil
+		}
+
+		return errors.Errorf("can not decode object into %s", v.Type())
+	}
+
+	return errors.Errorf("unknown type %d", typ)
+}
@@ -0,0 +1,166 @@
+package sbvj01
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/xhebox/bstruct/byteorder"
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+type color struct {
+	R, G, B uint8
+}
+
+// encoded as an array, like the game does
+func (c color) MarshalSBVJ() ([]byte, error) {
+	return Marshal([]uint8{c.R, c.G, c.B})
+}
+
+func (c *color) UnmarshalSBVJ(b []byte) error {
+	a := []uint8{}
+	if e := Unmarshal(b, &a); e != nil {
+		return e
+	}
+	c.R, c.G, c.B = a[0], a[1], a[2]
+	return nil
+}
+
+type base struct {
+	Id      String `sbvj:"id"`
+	Version Varint `sbvj:"version"`
+}
+
+type item struct {
+	base
+	Name    string                 `sbvj:"name"`
+	Count   int                    `sbvj:"count,omitempty"`
+	Price   float64                `sbvj:"price"`
+	Uuid    UUID                   `sbvj:"uuid"`
+	Color   color                  `sbvj:"color"`
+	Tags    []string               `sbvj:"tags,omitempty"`
+	Extra   map[string]interface{} `sbvj:"extra"`
+	Parent  *item                  `sbvj:"parent,omitempty"`
+	Ignored bool                   `sbvj:"-"`
+	private int
+}
+
+func TestMarshal(t *testing.T) {
+	in := item{
+		base:  base{Id: "item", Version: 3},
+		Name:  "perfectlygenericitem",
+		Price: 3,
+		Uuid:  UUID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		Color: color{255, 128, 0},
+		Extra: map[string]interface{}{"b": int64(1), "a": String("x")},
+		Parent: &item{
+			Name:  "parent",
+			Count: 2,
+			Tags:  []string{"a", "b"},
+		},
+		Ignored: true,
+	}
+
+	b, e := Marshal(in)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// the same bytes through the generic writer
+	generic, e := Read(bytes.NewReader(b))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	obj := generic.(Object)
+	keys := []String{}
+	for _, p := range obj {
+		keys = append(keys, p.Key)
+	}
+
+	expect := []String{"id", "version", "name", "price", "uuid", "color", "extra", "parent"}
+	if !reflect.DeepEqual(keys, expect) {
+		t.Fatalf("expect keys %v, got %v", expect, keys)
+	}
+
+	if v, _ := obj.Get("price"); v != float64(3) {
+		t.Fatalf("expect price to be a number, got %#v", v)
+	}
+
+	if v, _ := obj.Get("version"); v != int64(3) {
+		t.Fatalf("expect version to be a varint, got %#v", v)
+	}
+
+	if v, _ := obj.Get("uuid"); v != String("0102030405060708090a0b0c0d0e0f10") {
+		t.Fatalf("expect uuid to be a hex string, got %#v", v)
+	}
+
+	out := item{}
+	if e := Unmarshal(b, &out); e != nil {
+		t.Fatal(e)
+	}
+
+	in.Ignored = false
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("expect %+v, got %+v", in, out)
+	}
+}
+
+func TestUnmarshalConvert(t *testing.T) {
+	buf := &bytes.Buffer{}
+	Write(buf, Object{
+		{Key: "count", Value: float64(4)},
+		{Key: "price", Value: int64(7)},
+		{Key: "unknown", Value: []interface{}{Object{{Key: "x", Value: nil}}}},
+	})
+
+	out := item{}
+	if e := Unmarshal(buf.Bytes(), &out); e != nil {
+		t.Fatal(e)
+	}
+
+	if out.Count != 4 || out.Price != 7 {
+		t.Fatalf("unexpected %+v", out)
+	}
+
+	buf.Reset()
+	byteorder.PutUint8(buf, NumberT)
+	byteorder.PutFloat64(buf, byteorder.BigEndian, 1.5)
+
+	if e := Unmarshal(buf.Bytes(), &out.Count); e == nil {
+		t.Fatal("expect 1.5 not to fit an int")
+	}
+
+	number := func(f float64) []byte {
+		buf := &bytes.Buffer{}
+		byteorder.PutUint8(buf, NumberT)
+		byteorder.PutFloat64(buf, byteorder.BigEndian, f)
+		return buf.Bytes()
+	}
+
+	for _, f := range []float64{1e300, math.Inf(1), math.Inf(-1), math.NaN(), 9.3e18, 1 << 63, -1e19} {
+		var i int64
+		if e := Unmarshal(number(f), &i); e == nil {
+			t.Errorf("%v decoded into an int64 as %d", f, i)
+		}
+	}
+
+	for _, f := range []float64{1e300, math.Inf(1), 1 << 64, -1} {
+		var u uint64
+		if e := Unmarshal(number(f), &u); e == nil {
+			t.Errorf("%v decoded into a uint64 as %d", f, u)
+		}
+	}
+
+	var i int64
+	if e := Unmarshal(number(-(1 << 63)), &i); e != nil || i != math.MinInt64 {
+		t.Errorf("-2^63: %d %v", i, e)
+	}
+
+	var u uint64
+	if e := Unmarshal(number(1<<63), &u); e != nil || u != 1<<63 {
+		t.Errorf("2^63: %d %v", u, e)
+	}
+}
@@ -0,0 +1,129 @@
+package sbvj01
+
+import (
+	"strconv"
+	"strings"
+
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+// MergeOptions changes how Merge follows the game.
+type MergeOptions struct {
+	// The game turns an object with a "1" key into an array, indexed by its
+	// positive integer keys and losing the others, as sbmeta works around.
+	// PreserveNumberKeys leaves such objects alone.
+	PreserveNumberKeys bool
+}
+
+// MergeReport lists, as json pointers, the keys of the objects that the game
+// turns into arrays, whether or not they were preserved.
+type MergeReport struct {
+	Mangled []string
+}
+
+// Merge merges overlay into base like sb.jsonMerge: objects are merged key by
+// key recursively, a null in overlay deletes the key, and anything else in
+// overlay replaces what base has. Neither argument is modified.
+func Merge(base, overlay interface{}) interface{} {
+	r, _ := MergeWith(base, overlay, MergeOptions{})
+	return r
+}
+
+func MergeWith(base, overlay interface{}, opts MergeOptions) (interface{}, *MergeReport) {
+	rep := &MergeReport{}
+	r := merge(base, overlay)
+	return mangle(r, "", opts, rep), rep
+}
+
+func merge(base, overlay interface{}) interface{} {
+	o, ok := asObject(overlay)
+	if !ok {
+		return Copy(overlay)
+	}
+
+	b, ok := asObject(base)
+	if !ok {
+		b = Object{}
+	}
+
+	r := make(Object, 0, len(b)+len(o))
+	for _, p := range b {
+		r = append(r, Pair{Key: p.Key, Value: Copy(p.Value)})
+	}
+
+	for _, p := range o {
+		if p.Value == nil {
+			r.Delete(p.Key)
+			continue
+		}
+
+		k := r.index(p.Key)
+		if k == -1 {
+			r = append(r, Pair{Key: p.Key, Value: merge(nil, p.Value)})
+			continue
+		}
+
+		r[k].Value = merge(r[k].Value, p.Value)
+	}
+
+	return r
+}
+
+func escapePointer(k String) string {
+	return strings.Replace(strings.Replace(string(k), "~", "~0", -1), "/", "~1", -1)
+}
+
+// maxMangledIndex bounds the arrays made of mangled objects, whose larger
+// keys are dropped.
+const maxMangledIndex = 1 << 20
+
+// arrayIndex returns the array index of a positive integer key.
+func arrayIndex(k String) (int, bool) {
+	if len(k) == 0 || k[0] < '1' || k[0] > '9' {
+		return 0, false
+	}
+
+	i, e := strconv.Atoi(string(k))
+	return i - 1, e == nil && i <= maxMangledIndex
+}
+
+func mangle(v interface{}, path string, opts MergeOptions, rep *MergeReport) interface{} {
+	switch n := v.(type) {
+	case []interface{}:
+		for k := range n {
+			n[k] = mangle(n[k], path+"/"+strconv.Itoa(k), opts, rep)
+		}
+		return n
+	case Object:
+		for k := range n {
+			n[k].Value = mangle(n[k].Value, path+"/"+escapePointer(n[k].Key), opts, rep)
+		}
+
+		if _, ok := n.Get("1"); !ok {
+			return n
+		}
+
+		size := 0
+		for _, p := range n {
+			rep.Mangled = append(rep.Mangled, path+"/"+escapePointer(p.Key))
+
+			if i, ok := arrayIndex(p.Key); ok && i+1 > size {
+				size = i + 1
+			}
+		}
+
+		if opts.PreserveNumberKeys {
+			return n
+		}
+
+		r := make([]interface{}, size)
+		for _, p := range n {
+			if i, ok := arrayIndex(p.Key); ok {
+				r[i] = p.Value
+			}
+		}
+		return r
+	}
+
+	return v
+}
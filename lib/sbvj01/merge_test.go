@@ -0,0 +1,63 @@
+package sbvj01
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	lit := func(s string) interface{} {
+		v, e := ParseLiteral(s)
+		if e != nil {
+			t.Fatal(e)
+		}
+		return v
+	}
+
+	base := lit(`{"a":1,"o":{"x":1,"y":[1,2]},"d":"gone","s":{"k":1}}`)
+	overlay := lit(`{"o":{"y":[3],"z":{"n":null,"m":2}},"d":null,"s":"scalar","new":[{"a":null}]}`)
+
+	r := Merge(base, overlay)
+
+	want := `{"a":1,"o":{"x":1,"y":[3],"z":{"m":2}},"s":"scalar","new":[{"a":null}]}`
+	if got := dump(t, r); got != want {
+		t.Fatalf("got %s\nwant %s", got, want)
+	}
+
+	if dump(t, base) != `{"a":1,"o":{"x":1,"y":[1,2]},"d":"gone","s":{"k":1}}` {
+		t.Fatalf("base modified: %s", dump(t, base))
+	}
+
+	if got := dump(t, Merge(lit(`[1]`), lit(`{"a":1}`))); got != `{"a":1}` {
+		t.Fatalf("object over array: %s", got)
+	}
+
+	if got := dump(t, Merge(map[string]interface{}{"b": int64(1), "a": int64(2)}, Object{{Key: "c", Value: true}})); got != `{"a":2,"b":1,"c":true}` {
+		t.Fatalf("plain map: %s", got)
+	}
+}
+
+func TestMergeMangle(t *testing.T) {
+	base, e := ParseLiteral(`{"p":{"1":"a","3":"c","x":"lost"},"q":{"10":1},"r":[{"1":true}]}`)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	r, rep := MergeWith(base, Object{}, MergeOptions{})
+
+	want := `{"p":["a",null,"c"],"q":{"10":1},"r":[[true]]}`
+	if got := dump(t, r); got != want {
+		t.Fatalf("got %s\nwant %s", got, want)
+	}
+
+	mangled := []string{"/p/1", "/p/3", "/p/x", "/r/0/1"}
+	if !reflect.DeepEqual(rep.Mangled, mangled) {
+		t.Fatalf("report %v", rep.Mangled)
+	}
+
+	r, rep = MergeWith(base, Object{}, MergeOptions{PreserveNumberKeys: true})
+
+	if !Equal(r, base) || !reflect.DeepEqual(rep.Mangled, mangled) {
+		t.Fatalf("preserved %s, report %v", dump(t, r), rep.Mangled)
+	}
+}
@@ -0,0 +1,167 @@
+package sbvj01
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+// MigrateFunc upgrades a body by one version. It may modify body in place,
+// and returns the new one.
+type MigrateFunc func(body interface{}) (interface{}, error)
+
+// MissingMigrationError is returned when no migration upgrades Id from
+// Version.
+type MissingMigrationError struct {
+	Id      String
+	Version int32
+}
+
+func (e *MissingMigrationError) Error() string {
+	return fmt.Sprintf("no migration for %s from version %d", e.Id, e.Version)
+}
+
+type MigrationStep struct {
+	From, To int32
+}
+
+// MigrationReport tells what Migrate did, or what DryRun would do. Steps
+// lists the migrations applied in order, and Changed whether the body ended
+// up different, as Equal tells: a varint turned into a float is a change.
+type MigrationReport struct {
+	Id      String
+	From    int32
+	To      int32
+	Steps   []MigrationStep
+	Changed bool
+}
+
+// Registry holds migrations by header id and the version they upgrade from.
+// Unversioned documents are taken as version 0.
+type Registry struct {
+	mu         sync.RWMutex
+	migrations map[String]map[int32]MigrateFunc
+}
+
+func NewRegistry() *Registry {
+	return &Registry{migrations: map[String]map[int32]MigrateFunc{}}
+}
+
+// Register adds the migration of id from version to version+1.
+func (r *Registry) Register(id String, from int32, fn MigrateFunc) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.migrations[id]
+	if !ok {
+		m = map[int32]MigrateFunc{}
+		r.migrations[id] = m
+	}
+
+	if _, ok := m[from]; ok {
+		return errors.Errorf("migration for %s from version %d registered twice", id, from)
+	}
+
+	m[from] = fn
+	return nil
+}
+
+// Latest returns the version that id can be upgraded to from version.
+func (r *Registry) Latest(id String, from int32) int32 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for r.migrations[id][from] != nil {
+		from++
+	}
+
+	return from
+}
+
+func version(hdr VerJsonHdr) int32 {
+	if !hdr.Versioned {
+		return 0
+	}
+
+	return hdr.Version
+}
+
+func (r *Registry) migrate(doc Document, target int32) (interface{}, *MigrationReport, error) {
+	rep := &MigrationReport{Id: doc.Header.Id, From: version(doc.Header), To: target}
+
+	if rep.From > target {
+		return nil, rep, errors.Errorf("%s is at version %d, can not downgrade to %d", rep.Id, rep.From, target)
+	}
+
+	r.mu.RLock()
+	fns := make([]MigrateFunc, 0, target-rep.From)
+	for v := rep.From; v < target; v++ {
+		fn := r.migrations[rep.Id][v]
+		if fn == nil {
+			r.mu.RUnlock()
+			return nil, rep, &MissingMigrationError{Id: rep.Id, Version: v}
+		}
+
+		fns = append(fns, fn)
+	}
+	r.mu.RUnlock()
+
+	body := Copy(doc.Body)
+
+	for k, fn := range fns {
+		from := rep.From + int32(k)
+
+		var e error
+		body, e = fn(body)
+		if e != nil {
+			return nil, rep, errors.Wrapf(e, "migrate %s from version %d", rep.Id, from)
+		}
+
+		rep.Steps = append(rep.Steps, MigrationStep{From: from, To: from + 1})
+	}
+
+	rep.Changed = !Equal(body, doc.Body)
+
+	return body, rep, nil
+}
+
+// Migrate upgrades doc to the target version, and sets its header to it. On
+// failure, doc is left untouched.
+func (r *Registry) Migrate(doc *Document, target int32) (*MigrationReport, error) {
+	body, rep, e := r.migrate(*doc, target)
+	if e != nil {
+		return rep, e
+	}
+
+	if len(rep.Steps) != 0 {
+		doc.Body = body
+		doc.Header.Versioned = true
+		doc.Header.Version = target
+	}
+
+	return rep, nil
+}
+
+// DryRun runs the migrations of Migrate on a copy, and reports what they
+// would do.
+func (r *Registry) DryRun(doc Document, target int32) (*MigrationReport, error) {
+	_, rep, e := r.migrate(doc, target)
+	return rep, e
+}
+
+// Migrations is the registry used by Register and Migrate, for migrations
+// that should be available to every tool.
+var Migrations = NewRegistry()
+
+// Register adds a migration to Migrations, and panics if it is a duplicate.
+func Register(id String, from int32, fn MigrateFunc) {
+	if e := Migrations.Register(id, from, fn); e != nil {
+		panic(e)
+	}
+}
+
+func Migrate(doc *Document, target int32) (*MigrationReport, error) {
+	return Migrations.Migrate(doc, target)
+}
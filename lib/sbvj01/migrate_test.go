@@ -0,0 +1,114 @@
+package sbvj01
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+func TestMigrate(t *testing.T) {
+	r := NewRegistry()
+
+	rename := func(body interface{}) (interface{}, error) {
+		o := body.(Object)
+		v, _ := o.Get("name")
+		o.Delete("name")
+		o.Set("identity", Object{{Key: "name", Value: v}})
+		return o, nil
+	}
+
+	bump := func(body interface{}) (interface{}, error) {
+		o := body.(Object)
+		o.Set("level", int64(1))
+		return o, nil
+	}
+
+	if e := r.Register("Player", 1, rename); e != nil {
+		t.Fatal(e)
+	}
+	if e := r.Register("Player", 2, bump); e != nil {
+		t.Fatal(e)
+	}
+	if e := r.Register("Player", 2, bump); e == nil {
+		t.Fatal("registered twice")
+	}
+
+	if v := r.Latest("Player", 1); v != 3 {
+		t.Fatalf("latest %d", v)
+	}
+
+	doc := Document{
+		Header: VerJsonHdr{Id: "Player", Versioned: true, Version: 1},
+		Body:   Object{{Key: "name", Value: "hero"}},
+	}
+
+	rep, e := r.DryRun(doc, 3)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if len(rep.Steps) != 2 || !rep.Changed || doc.Header.Version != 1 || len(doc.Body.(Object)) != 1 {
+		t.Fatalf("dry run %+v changed %+v", rep, doc)
+	}
+
+	if _, e := r.Migrate(&doc, 4); e == nil {
+		t.Fatal("migrated past the last version")
+	} else if m, ok := errors.Cause(e).(*MissingMigrationError); !ok || m.Version != 3 {
+		t.Fatalf("got %v", e)
+	}
+
+	if doc.Header.Version != 1 {
+		t.Fatal("failed migration changed the header")
+	}
+
+	rep, e = r.Migrate(&doc, 3)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	want := Object{
+		{Key: "identity", Value: Object{{Key: "name", Value: "hero"}}},
+		{Key: "level", Value: int64(1)},
+	}
+	if !Equal(doc.Body, want) || doc.Header.Version != 3 || len(rep.Steps) != 2 {
+		t.Fatalf("got %+v %+v", doc, rep)
+	}
+
+	if _, e := r.Migrate(&doc, 2); e == nil {
+		t.Fatal("downgraded")
+	}
+
+	rep, e = r.Migrate(&doc, 3)
+	if e != nil || len(rep.Steps) != 0 || rep.Changed {
+		t.Fatalf("no-op migration %+v %v", rep, e)
+	}
+}
+
+func TestMigrateChanged(t *testing.T) {
+	r := NewRegistry()
+
+	set := func(v interface{}) MigrateFunc {
+		return func(body interface{}) (interface{}, error) {
+			o := body.(Object)
+			o.Set("n", v)
+			return o, nil
+		}
+	}
+
+	r.Register("Float", 0, set(float64(1)))
+	r.Register("Big", 0, set(int64(1<<53+1)))
+	r.Register("Same", 0, set(int64(1)))
+
+	for id, want := range map[String]bool{"Float": true, "Big": true, "Same": false} {
+		doc := Document{Header: VerJsonHdr{Id: id}, Body: Object{{Key: "n", Value: int64(1 << 53)}}}
+		if id != "Big" {
+			doc.Body = Object{{Key: "n", Value: int64(1)}}
+		}
+
+		rep, e := r.DryRun(doc, 1)
+		if e != nil || rep.Changed != want {
+			t.Errorf("%s: changed %v, want %v: %v", id, rep.Changed, want, e)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package sbvj01
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+type Pair struct {
+	Key   String
+	Value interface{}
+}
+
+// Object is a versioned json object that keeps its keys in order, so that
+// reading and writing it back gives the same bytes.
+type Object []Pair
+
+func (o Object) index(key String) int {
+	for k := range o {
+		if o[k].Key == key {
+			return k
+		}
+	}
+
+	return -1
+}
+
+func (o Object) Get(key String) (interface{}, bool) {
+	k := o.index(key)
+	if k == -1 {
+		return nil, false
+	}
+
+	return o[k].Value, true
+}
+
+// Set replaces the value of key in place, or appends it.
+func (o *Object) Set(key String, value interface{}) {
+	k := o.index(key)
+	if k == -1 {
+		*o = append(*o, Pair{Key: key, Value: value})
+		return
+	}
+
+	(*o)[k].Value = value
+}
+
+func (o *Object) Delete(key String) bool {
+	k := o.index(key)
+	if k == -1 {
+		return false
+	}
+
+	*o = append((*o)[:k], (*o)[k+1:]...)
+	return true
+}
+
+func (o Object) Map() map[String]interface{} {
+	r := make(map[String]interface{}, len(o))
+	for _, p := range o {
+		r[p.Key] = p.Value
+	}
+	return r
+}
+
+func sortedObject(m map[String]interface{}) Object {
+	r := make(Object, 0, len(m))
+	for k, v := range m {
+		r = append(r, Pair{Key: k, Value: v})
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].Key < r[j].Key })
+	return r
+}
+
+func (o Object) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	buf.WriteByte('{')
+
+	for k, p := range o {
+		if k != 0 {
+			buf.WriteByte(',')
+		}
+
+		key, e := json.Marshal(string(p.Key))
+		if e != nil {
+			return nil, e
+		}
+		buf.Write(key)
+
+		buf.WriteByte(':')
+
+		value, e := json.Marshal(p.Value)
+		if e != nil {
+			return nil, e
+		}
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func (o *Object) UnmarshalJSON(b []byte) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	r, e := decodeJSON(d, nil)
+	if e != nil {
+		return e
+	}
+
+	obj, ok := r.(Object)
+	if !ok {
+		return errors.Errorf("expect an object, got %T", r)
+	}
+
+	*o = obj
+	return nil
+}
+
+// DecodeJSON reads one json value like encoding/json with UseNumber, except
+// that objects are decoded as Object, keeping their keys in order.
+func DecodeJSON(rd io.Reader) (interface{}, error) {
+	d := json.NewDecoder(rd)
+	d.UseNumber()
+
+	return decodeJSON(d, nil)
+}
+
+// DecodeRawJSON is DecodeJSON, except that strings keep their invalid utf-8
+// as is, where encoding/json puts U+FFFD, so that a StringPolicy sees them.
+func DecodeRawJSON(data []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	return decodeJSON(d, data)
+}
+
+// unquote decodes the json string at the start of b like encoding/json, but
+// copies invalid utf-8 instead of replacing it.
+func unquote(b []byte) string {
+	r := make([]byte, 0, len(b))
+	hex := func(i int) rune {
+		if i+4 >= len(b) {
+			return utf8.RuneError
+		}
+
+		n, _ := strconv.ParseUint(string(b[i+1:i+5]), 16, 16)
+		return rune(n)
+	}
+
+	for i := 1; i < len(b) && b[i] != '"'; i++ {
+		if b[i] != '\\' {
+			r = append(r, b[i])
+			continue
+		}
+
+		i++
+		if i == len(b) {
+			break
+		}
+
+		switch b[i] {
+		case 'b':
+			r = append(r, '\b')
+		case 'f':
+			r = append(r, '\f')
+		case 'n':
+			r = append(r, '\n')
+		case 'r':
+			r = append(r, '\r')
+		case 't':
+			r = append(r, '\t')
+		case 'u':
+			c := hex(i)
+			i += 4
+
+			if utf16.IsSurrogate(c) {
+				c2 := utf8.RuneError
+				if i+2 < len(b) && b[i+1] == '\\' && b[i+2] == 'u' {
+					c2 = hex(i + 2)
+				}
+
+				if p := utf16.DecodeRune(c, c2); p != utf8.RuneError {
+					c = p
+					i += 6
+				} else {
+					c = utf8.RuneError
+				}
+			}
+
+			var buf [utf8.UTFMax]byte
+			r = append(r, buf[:utf8.EncodeRune(buf[:], c)]...)
+		default:
+			r = append(r, b[i])
+		}
+	}
+
+	return string(r)
+}
+
+// token is d.Token, except that strings are read again from raw, if set,
+// when encoding/json replaced invalid utf-8 in them.
+func token(d *json.Decoder, raw []byte) (json.Token, error) {
+	off := d.InputOffset()
+
+	tok, e := d.Token()
+	if s, ok := tok.(string); ok && raw != nil && strings.ContainsRune(s, utf8.RuneError) {
+		lit := raw[off:d.InputOffset()]
+		tok = unquote(lit[bytes.IndexByte(lit, '"'):])
+	}
+
+	return tok, e
+}
+
+func decodeJSON(d *json.Decoder, raw []byte) (interface{}, error) {
+	tok, e := token(d, raw)
+	if e != nil {
+		return nil, e
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '[':
+			r := []interface{}{}
+
+			for d.More() {
+				v, e := decodeJSON(d, raw)
+				if e != nil {
+					return nil, e
+				}
+
+				r = append(r, v)
+			}
+
+			if _, e := d.Token(); e != nil {
+				return nil, e
+			}
+
+			return r, nil
+		case '{':
+			r := Object{}
+
+			for d.More() {
+				key, e := token(d, raw)
+				if e != nil {
+					return nil, e
+				}
+
+				v, e := decodeJSON(d, raw)
+				if e != nil {
+					return nil, e
+				}
+
+				r = append(r, Pair{Key: String(key.(string)), Value: v})
+			}
+
+			if _, e := d.Token(); e != nil {
+				return nil, e
+			}
+
+			return r, nil
+		default:
+			return nil, errors.Errorf("unexpected %v", t)
+		}
+	default:
+		return t, nil
+	}
+}
@@ -0,0 +1,624 @@
+package sbvj01
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+type stepKind byte
+
+const (
+	keyStep stepKind = iota
+	indexStep
+	wildcardStep
+	filterStep
+)
+
+type step struct {
+	kind  stepKind
+	key   String
+	index int
+	// filter
+	sub   Path
+	op    string
+	value interface{}
+	typed bool
+}
+
+// Path selects values of a document, like a tiny jq:
+//
+//	.name .name[0] ["odd key"] [-1] .* [*]
+//	[?.name == "sword"] [?.count >= varint:5] [?.tags]
+//
+// A negative index counts from the end. A filter keeps the elements of an
+// array, or the values of an object, for which the sub path compares true
+// against the literal; without an operator, it keeps those where the sub path
+// exists. Numbers compare by value, whether varints or floats, except that a
+// typed literal, like varint:5, is only equal to a number of its type. An
+// empty path, or ".", selects the whole document.
+type Path []step
+
+func isIdent(c byte) bool {
+	return c == '_' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// scanString returns the length of the json string at the start of s.
+func scanString(s string) (int, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+
+	return 0, errors.New("unterminated string")
+}
+
+// scanLiteral returns the length of the literal at the start of s, which ends
+// at the ] closing the filter. The brackets and braces of json arrays and
+// objects are matched, and strings skipped, so they can hold a ].
+func scanLiteral(s string) (int, error) {
+	depth := 0
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			n, e := scanString(s[i:])
+			if e != nil {
+				return 0, e
+			}
+			i += n - 1
+		case '[', '{':
+			depth++
+		case '}':
+			depth--
+		case ']':
+			if depth == 0 {
+				return i, nil
+			}
+			depth--
+		}
+	}
+
+	return -1, nil
+}
+
+func ParsePath(s string) (Path, error) {
+	p, rest, e := parsePath(strings.TrimSpace(s), false)
+	if e != nil {
+		return nil, e
+	}
+
+	if rest != "" {
+		return nil, errors.Errorf("unexpected %q", rest)
+	}
+
+	return p, nil
+}
+
+// parsePath parses steps until the end of s, or an operator/] when sub is
+// set, and returns what is left.
+func parsePath(s string, sub bool) (Path, string, error) {
+	p := Path{}
+
+	if s == "." || sub && len(s) > 1 && s[0] == '.' && !isIdent(s[1]) && s[1] != '*' && s[1] != '[' {
+		s = s[1:]
+	}
+
+	for len(s) != 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+
+			if strings.HasPrefix(s, "*") {
+				p = append(p, step{kind: wildcardStep})
+				s = s[1:]
+				continue
+			}
+
+			if strings.HasPrefix(s, "[") {
+				continue
+			}
+
+			i := 0
+			for i < len(s) && isIdent(s[i]) {
+				i++
+			}
+
+			if i == 0 {
+				return nil, s, errors.Errorf("expect a key at %q", s)
+			}
+
+			p = append(p, step{kind: keyStep, key: String(s[:i])})
+			s = s[i:]
+		case '[':
+			s = strings.TrimLeft(s[1:], " ")
+
+			switch {
+			case strings.HasPrefix(s, "*"):
+				p = append(p, step{kind: wildcardStep})
+				s = s[1:]
+			case strings.HasPrefix(s, "\""):
+				n, e := scanString(s)
+				if e != nil {
+					return nil, s, e
+				}
+
+				var key string
+				if e := json.Unmarshal([]byte(s[:n]), &key); e != nil {
+					return nil, s, errors.Wrapf(e, "bad key %s", s[:n])
+				}
+
+				p = append(p, step{kind: keyStep, key: String(key)})
+				s = s[n:]
+			case strings.HasPrefix(s, "?"):
+				f, rest, e := parseFilter(strings.TrimLeft(s[1:], " "))
+				if e != nil {
+					return nil, s, e
+				}
+
+				p = append(p, f)
+				s = rest
+			default:
+				i := 0
+				if strings.HasPrefix(s, "-") {
+					i++
+				}
+				for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+					i++
+				}
+
+				n, e := strconv.Atoi(s[:i])
+				if e != nil {
+					return nil, s, errors.Errorf("expect an index at %q", s)
+				}
+
+				p = append(p, step{kind: indexStep, index: n})
+				s = s[i:]
+			}
+
+			s = strings.TrimLeft(s, " ")
+			if !strings.HasPrefix(s, "]") {
+				return nil, s, errors.Errorf("expect ] at %q", s)
+			}
+			s = s[1:]
+		default:
+			// a leading key may omit its dot
+			if len(p) == 0 && isIdent(s[0]) {
+				s = "." + s
+				continue
+			}
+
+			if sub {
+				return p, s, nil
+			}
+
+			return nil, s, errors.Errorf("unexpected %q", s)
+		}
+	}
+
+	return p, s, nil
+}
+
+var operators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func parseFilter(s string) (step, string, error) {
+	f := step{kind: filterStep}
+
+	sub, s, e := parsePath(s, true)
+	if e != nil {
+		return f, s, e
+	}
+	f.sub = sub
+
+	s = strings.TrimLeft(s, " ")
+	for _, op := range operators {
+		if strings.HasPrefix(s, op) {
+			f.op = op
+			s = strings.TrimLeft(s[len(op):], " ")
+			break
+		}
+	}
+
+	if f.op == "" {
+		return f, s, nil
+	}
+
+	n, e := scanLiteral(s)
+	if e != nil {
+		return f, s, e
+	}
+
+	if n == -1 {
+		return f, s, errors.New("unterminated filter")
+	}
+
+	lit := strings.TrimSpace(s[:n])
+
+	f.value, e = ParseLiteral(lit)
+	if e != nil {
+		return f, s, e
+	}
+
+	f.typed = typedLiteral(lit)
+
+	return f, s[n:], nil
+}
+
+// typedLiteral tells if s is prefixed by a type for ParseLiteral.
+func typedLiteral(s string) bool {
+	if i := strings.IndexByte(s, ':'); i != -1 {
+		switch s[:i] {
+		case "varint", "float", "string", "bool":
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseLiteral parses a value for a path or an edit. It is json, optionally
+// prefixed by a type: varint:5, float:5, string:text, bool:true. Untyped json
+// integers become varints, and other json numbers floats.
+func ParseLiteral(s string) (interface{}, error) {
+	if typedLiteral(s) {
+		i := strings.IndexByte(s, ':')
+		v := s[i+1:]
+
+		switch s[:i] {
+		case "varint":
+			return strconv.ParseInt(v, 10, 64)
+		case "float":
+			return strconv.ParseFloat(v, 64)
+		case "string":
+			return String(v), nil
+		case "bool":
+			return strconv.ParseBool(v)
+		}
+	}
+
+	r, e := DecodeJSON(strings.NewReader(s))
+	if e != nil {
+		return nil, errors.Wrapf(e, "bad literal %q", s)
+	}
+
+	return Normalize(r), nil
+}
+
+// number returns v as an int64 or a float64, and false if it is not a number.
+func number(v interface{}) (interface{}, bool) {
+	switch v.(type) {
+	case json.Number, string:
+		v = Normalize(v)
+	}
+
+	switch v.(type) {
+	case int64, float64:
+		return v, true
+	}
+
+	return nil, false
+}
+
+// compareInt compares an integer with a float exactly, which converting the
+// integer to float64 would not do above 2^53.
+func compareInt(i int64, f float64) (int, bool) {
+	switch {
+	case math.IsNaN(f):
+		return 0, false
+	case f >= math.MaxInt64:
+		return -1, true
+	case f < math.MinInt64:
+		return 1, true
+	}
+
+	t := math.Trunc(f)
+
+	switch j := int64(t); {
+	case i < j:
+		return -1, true
+	case i > j:
+		return 1, true
+	case f > t:
+		return -1, true
+	case f < t:
+		return 1, true
+	}
+
+	return 0, true
+}
+
+func compareNumber(a, b interface{}) (int, bool) {
+	switch x := a.(type) {
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		case float64:
+			return compareInt(x, y)
+		}
+	case float64:
+		switch y := b.(type) {
+		case int64:
+			c, ok := compareInt(y, x)
+			return -c, ok
+		case float64:
+			switch {
+			case math.IsNaN(x) || math.IsNaN(y):
+				return 0, false
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+
+	return 0, false
+}
+
+func text(v interface{}) (string, bool) {
+	switch n := v.(type) {
+	case String:
+		return string(n), true
+	case string:
+		return n, true
+	}
+
+	return "", false
+}
+
+// compare returns -1, 0 or 1, and false if a and b are not ordered. Numbers
+// compare by value, whether they are varints or floats.
+func compare(a, b interface{}) (int, bool) {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		if !ok {
+			return 0, false
+		}
+		return compareNumber(x, y)
+	}
+
+	if x, ok := text(a); ok {
+		y, ok := text(b)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	}
+
+	return 0, false
+}
+
+// equal tells if v equals the literal of f. An untyped number equals a
+// varint or a float of the same value, while a typed one, like varint:5,
+// only equals a number of its type.
+func (f step) equal(v interface{}, c int, ordered bool) bool {
+	if !ordered {
+		return Equal(v, f.value)
+	}
+
+	if x, ok := number(v); ok && f.typed {
+		y, _ := number(f.value)
+		_, xi := x.(int64)
+		_, yi := y.(int64)
+		if xi != yi {
+			return false
+		}
+	}
+
+	return c == 0
+}
+
+func (f step) match(v interface{}) bool {
+	found := f.sub.Get(v)
+
+	for _, r := range found {
+		if f.op == "" {
+			return true
+		}
+
+		c, ok := compare(r, f.value)
+
+		switch f.op {
+		case "==":
+			if f.equal(r, c, ok) {
+				return true
+			}
+		case "!=":
+			if !f.equal(r, c, ok) {
+				return true
+			}
+		case "<":
+			if ok && c < 0 {
+				return true
+			}
+		case "<=":
+			if ok && c <= 0 {
+				return true
+			}
+		case ">":
+			if ok && c > 0 {
+				return true
+			}
+		case ">=":
+			if ok && c >= 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Get returns every value selected by p.
+func (p Path) Get(root interface{}) []interface{} {
+	r := []interface{}{}
+
+	p.Update(root, func(v interface{}, exist bool) (interface{}, bool, error) {
+		if exist {
+			r = append(r, v)
+		}
+		return v, exist, nil
+	})
+
+	return r
+}
+
+// UpdateFunc is called on every selected value, and returns its replacement,
+// or false to delete it. exist is false for a missing key that a final key
+// step names; returning true then adds it.
+type UpdateFunc func(v interface{}, exist bool) (interface{}, bool, error)
+
+// Update calls fn on every value selected by p, and returns the new root
+// along with the number of values fn was applied to, which counts a missing
+// key only when it is added. Objects and arrays are modified in place, so
+// root should be dropped if an error is returned. The root itself can not be
+// deleted.
+func (p Path) Update(root interface{}, fn UpdateFunc) (interface{}, int, error) {
+	n := 0
+
+	r, keep, e := p.update(root, fn, &n)
+	if e != nil {
+		return root, n, e
+	}
+
+	if !keep {
+		return root, n, errors.New("can not delete the whole document")
+	}
+
+	return r, n, nil
+}
+
+func (p Path) update(v interface{}, fn UpdateFunc, n *int) (interface{}, bool, error) {
+	if len(p) == 0 {
+		*n++
+		return fn(v, true)
+	}
+
+	s, rest := p[0], p[1:]
+
+	switch s.kind {
+	case keyStep:
+		obj, ok := v.(Object)
+		if !ok {
+			return v, true, nil
+		}
+
+		k := obj.index(s.key)
+		if k == -1 {
+			if len(rest) != 0 {
+				return v, true, nil
+			}
+
+			r, keep, e := fn(nil, false)
+			if e != nil || !keep {
+				return v, true, e
+			}
+
+			*n++
+
+			return append(obj, Pair{Key: s.key, Value: r}), true, nil
+		}
+
+		r, keep, e := rest.update(obj[k].Value, fn, n)
+		if e != nil {
+			return v, true, e
+		}
+
+		if !keep {
+			obj.Delete(s.key)
+			return obj, true, nil
+		}
+
+		obj[k].Value = r
+		return obj, true, nil
+	case indexStep:
+		arr, ok := v.([]interface{})
+		if !ok {
+			return v, true, nil
+		}
+
+		k := s.index
+		if k < 0 {
+			k += len(arr)
+		}
+
+		if k < 0 || k >= len(arr) {
+			return v, true, nil
+		}
+
+		r, keep, e := rest.update(arr[k], fn, n)
+		if e != nil {
+			return v, true, e
+		}
+
+		if !keep {
+			return append(arr[:k], arr[k+1:]...), true, nil
+		}
+
+		arr[k] = r
+		return arr, true, nil
+	case wildcardStep, filterStep:
+		switch c := v.(type) {
+		case []interface{}:
+			r := c[:0]
+
+			for _, elem := range c {
+				if s.kind == filterStep && !s.match(elem) {
+					r = append(r, elem)
+					continue
+				}
+
+				nv, keep, e := rest.update(elem, fn, n)
+				if e != nil {
+					return v, true, e
+				}
+
+				if keep {
+					r = append(r, nv)
+				}
+			}
+
+			return r, true, nil
+		case Object:
+			r := c[:0]
+
+			for _, pair := range c {
+				if s.kind == filterStep && !s.match(pair.Value) {
+					r = append(r, pair)
+					continue
+				}
+
+				nv, keep, e := rest.update(pair.Value, fn, n)
+				if e != nil {
+					return v, true, e
+				}
+
+				if keep {
+					r = append(r, Pair{Key: pair.Key, Value: nv})
+				}
+			}
+
+			return r, true, nil
+		}
+
+		return v, true, nil
+	}
+
+	return v, true, nil
+}
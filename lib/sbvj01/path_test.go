@@ -0,0 +1,170 @@
+package sbvj01
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+func mustPath(t *testing.T, s string) Path {
+	p, e := ParsePath(s)
+	if e != nil {
+		t.Fatalf("%s: %+v", s, e)
+	}
+	return p
+}
+
+func pathDoc(t *testing.T) interface{} {
+	v, e := ParseLiteral(`{
+		"name": "hero",
+		"items": [
+			{"name": "sword", "count": 1},
+			{"name": "potion", "count": 5.0},
+			{"name": "arrow", "count": 30, "tags": ["ammo"]}
+		],
+		"odd key": {"x": null}
+	}`)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return v
+}
+
+func dump(t *testing.T, v interface{}) string {
+	b, e := json.Marshal(v)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return string(b)
+}
+
+func TestPathGet(t *testing.T) {
+	doc := pathDoc(t)
+
+	for path, want := range map[string]string{
+		".":                                   `[` + dump(t, doc) + `]`,
+		"name":                                `["hero"]`,
+		".items[0].name":                      `["sword"]`,
+		".items[-1].count":                    `[30]`,
+		".items[*].name":                      `["sword","potion","arrow"]`,
+		`["odd key"].x`:                       `[null]`,
+		`.items[?.name == "potion"].count`:    `[5]`,
+		".items[?.count >= 5].name":           `["potion","arrow"]`,
+		".items[?.count > varint:5].name":     `["arrow"]`,
+		".items[?.tags].name":                 `["arrow"]`,
+		".items[?.tags[0] == \"ammo\"].count": `[30]`,
+		`.items[?.tags == ["ammo"]].name`:     `["arrow"]`,
+		`.items[?.tags != ["]"]].count`:       `[30]`,
+		".missing":                            `[]`,
+		".items[7]":                           `[]`,
+	} {
+		if got := dump(t, mustPath(t, path).Get(doc)); got != want {
+			t.Errorf("%s: got %s, want %s", path, got, want)
+		}
+	}
+
+	for path, want := range map[string]string{
+		".items[?.count == 5].name":                 `["potion"]`,
+		".items[?.count == varint:5].name":          `[]`,
+		".items[?.count == float:5].name":           `["potion"]`,
+		".items[?.count != varint:30].name":         `["sword","potion"]`,
+		"[?.id == varint:9007199254740993].name":    `[]`,
+		"[?.id == 9007199254740993].name":           `[]`,
+		"[?.id == 9007199254740992].name":           `["big"]`,
+		"[?.id < 9007199254740993].name":            `["big"]`,
+		"[?.id == float:9007199254740992].name":     `[]`,
+		"[?.fid == float:9007199254740992].name":    `["float"]`,
+		"[?.fid == varint:9007199254740992].name":   `[]`,
+		"[?.fid > 9007199254740991].name":           `["float"]`,
+		"[?.fid > varint:9223372036854775807].name": `[]`,
+	} {
+		doc, e := ParseLiteral(`[{"name": "big", "id": 9007199254740992}, {"name": "float", "fid": 9007199254740992.0}]`)
+		if path[0] == '.' {
+			doc = pathDoc(t)
+		}
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		if got := dump(t, mustPath(t, path).Get(doc)); got != want {
+			t.Errorf("%s: got %s, want %s", path, got, want)
+		}
+	}
+
+	for _, bad := range []string{"..", "[", ".items[x]", `[?.a == ]`, `.a b`} {
+		if _, e := ParsePath(bad); e == nil {
+			t.Errorf("%s: no error", bad)
+		}
+	}
+}
+
+func TestPathUpdate(t *testing.T) {
+	doc := pathDoc(t)
+
+	set := func(value interface{}) UpdateFunc {
+		return func(v interface{}, exist bool) (interface{}, bool, error) {
+			return value, true, nil
+		}
+	}
+	del := func(v interface{}, exist bool) (interface{}, bool, error) {
+		return v, false, nil
+	}
+
+	doc, n, e := mustPath(t, ".items[?.count < 10].count").Update(doc, set(int64(99)))
+	if e != nil || n != 2 {
+		t.Fatalf("set: %d %+v", n, e)
+	}
+
+	doc, n, e = mustPath(t, ".level").Update(doc, set(float64(3)))
+	if e != nil || n != 1 {
+		t.Fatalf("add: %d %+v", n, e)
+	}
+
+	doc, n, e = mustPath(t, `.items[?.name != "arrow"]`).Update(doc, del)
+	if e != nil || n != 2 {
+		t.Fatalf("delete: %d %+v", n, e)
+	}
+
+	doc, n, e = mustPath(t, `["odd key"]`).Update(doc, del)
+	if e != nil || n != 1 {
+		t.Fatalf("delete key: %d %+v", n, e)
+	}
+
+	doc, n, e = mustPath(t, ".nothing").Update(doc, del)
+	if e != nil || n != 0 {
+		t.Fatalf("delete missing: %d %+v", n, e)
+	}
+
+	want := `{"name":"hero","items":[{"name":"arrow","count":30,"tags":["ammo"]}],"level":3}`
+	if got := dump(t, doc); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	if _, _, e := mustPath(t, ".").Update(doc, del); e == nil || !strings.Contains(e.Error(), "whole") {
+		t.Fatalf("deleted the root: %v", e)
+	}
+}
+
+func TestParseLiteral(t *testing.T) {
+	for s, want := range map[string]interface{}{
+		"varint:5":   int64(5),
+		"float:5":    float64(5),
+		"5":          int64(5),
+		"5.5":        5.5,
+		"string:a:b": String("a:b"),
+		`"a:b"`:      String("a:b"),
+		"bool:true":  true,
+		"null":       nil,
+	} {
+		v, e := ParseLiteral(s)
+		if e != nil {
+			t.Fatalf("%s: %+v", s, e)
+		}
+
+		if v != want {
+			t.Errorf("%s: got %#v, want %#v", s, v, want)
+		}
+	}
+}
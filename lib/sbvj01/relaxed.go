@@ -0,0 +1,145 @@
+package sbvj01
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// SyntaxError is a json error located by line and column, both from 1.
+type SyntaxError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+func syntaxError(data []byte, off int, msg string) *SyntaxError {
+	if off > len(data) {
+		off = len(data)
+	} else if off < 0 {
+		off = 0
+	}
+
+	line := bytes.Count(data[:off], []byte{'\n'}) + 1
+	col := off - bytes.LastIndexByte(data[:off], '\n')
+
+	return &SyntaxError{Line: line, Column: col, Msg: msg}
+}
+
+// relax blanks out comments and trailing commas with spaces, so that the
+// result is plain json with every byte at the same offset.
+func relax(data []byte) ([]byte, error) {
+	r := make([]byte, len(data))
+	copy(r, data)
+
+	// the comma that may be trailing, and the byte before it
+	comma := -1
+	var prev byte
+
+	for i := 0; i < len(r); i++ {
+		switch c := r[i]; {
+		case c == '"':
+			for i++; i < len(r) && r[i] != '"'; i++ {
+				if r[i] == '\\' {
+					i++
+				}
+			}
+		case c == '/' && i+1 < len(r) && r[i+1] == '/':
+			for ; i < len(r) && r[i] != '\n'; i++ {
+				r[i] = ' '
+			}
+			continue
+		case c == '/' && i+1 < len(r) && r[i+1] == '*':
+			start := i
+
+			end := bytes.Index(r[i+2:], []byte("*/"))
+			if end == -1 {
+				return nil, syntaxError(data, start, "unterminated comment")
+			}
+
+			for end += i + 4; i < end; i++ {
+				if r[i] != '\n' {
+					r[i] = ' '
+				}
+			}
+			i--
+			continue
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			continue
+		case c == '}' || c == ']':
+			if comma != -1 {
+				r[comma] = ' '
+			}
+		case c == ',' && prev != '[' && prev != '{' && prev != ',':
+			comma = i
+			prev = c
+			continue
+		}
+
+		comma = -1
+		prev = r[i]
+	}
+
+	return r, nil
+}
+
+// DecodeRelaxedJSON is DecodeJSON for json as the game reads it, with //
+// and /* */ comments and trailing commas. Errors are *SyntaxError.
+func DecodeRelaxedJSON(rd io.Reader) (interface{}, error) {
+	data, e := ioutil.ReadAll(rd)
+	if e != nil {
+		return nil, e
+	}
+
+	return decodeRelaxed(data, false)
+}
+
+// DecodeRelaxedRawJSON is DecodeRelaxedJSON with the strings of
+// DecodeRawJSON.
+func DecodeRelaxedRawJSON(data []byte) (interface{}, error) {
+	return decodeRelaxed(data, true)
+}
+
+func decodeRelaxed(data []byte, raw bool) (interface{}, error) {
+	plain, e := relax(data)
+	if e != nil {
+		return nil, e
+	}
+
+	d := json.NewDecoder(bytes.NewReader(plain))
+	d.UseNumber()
+
+	var src []byte
+	if raw {
+		src = plain
+	}
+
+	r, e := decodeJSON(d, src)
+	if t, ok := e.(*json.SyntaxError); ok {
+		// the offset is past the bad byte, unless the input ended early
+		off := int(t.Offset) - 1
+		if t.Error() == "unexpected end of JSON input" {
+			off = len(data)
+		}
+
+		return nil, syntaxError(data, off, t.Error())
+	} else if e == io.EOF || e == io.ErrUnexpectedEOF {
+		return nil, syntaxError(data, len(data), "unexpected end of input")
+	} else if e != nil {
+		return nil, syntaxError(data, int(d.InputOffset()), e.Error())
+	}
+
+	// only blanks and comments may follow
+	off := int(d.InputOffset())
+	if rest := bytes.TrimLeft(plain[off:], " \t\r\n"); len(rest) != 0 {
+		return nil, syntaxError(data, len(plain)-len(rest), "unexpected data after the value")
+	}
+
+	return r, nil
+}
@@ -0,0 +1,61 @@
+package sbvj01
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestRelaxedJSON(t *testing.T) {
+	in := `// an object
+{
+	"b": 1, /* one */
+	"a": [1.0, 2, "x // not a comment", "y /* nor this */",],
+	"s": "quote \" and comma ,]",
+	/* multi
+	   line */
+	"o": {"k": null,},
+}
+// trailing
+`
+
+	v, e := DecodeRelaxedJSON(strings.NewReader(in))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	b, e := json.Marshal(v)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	want := `{"b":1,"a":[1.0,2,"x // not a comment","y /* nor this */"],"s":"quote \" and comma ,]","o":{"k":null}}`
+	if string(b) != want {
+		t.Fatalf("got %s\nwant %s", b, want)
+	}
+
+	if _, ok := v.(Object)[1].Value.([]interface{})[0].(json.Number); !ok {
+		t.Fatal("numbers are not json.Number")
+	}
+
+	for s, pos := range map[string][2]int{
+		"{\n\t\"a\": 1,\n\t\"b\" 2\n}": {3, 6},
+		"[1,\n2,\n/* open":             {3, 1},
+		"[1, 2":                        {1, 6},
+		"{}\n x":                       {2, 2},
+		"[,]":                          {1, 2},
+	} {
+		_, e := DecodeRelaxedJSON(strings.NewReader(s))
+
+		se, ok := errors.Cause(e).(*SyntaxError)
+		if !ok {
+			t.Fatalf("%q: got %v", s, e)
+		}
+
+		if se.Line != pos[0] || se.Column != pos[1] {
+			t.Errorf("%q: got %v, want line %d column %d", s, se, pos[0], pos[1])
+		}
+	}
+}
@@ -52,9 +52,7 @@ func ReadHdr(rd io.Reader) (r VerJsonHdr, e error) {
 }
 
 func WriteHdr(wt io.Writer, r VerJsonHdr) error {
-	strlen := len(r.Id)
-
-	if e := byteorder.PutUVarint(wt, byteorder.BigEndian, uint64(strlen)); e != nil {
+	if e := r.Id.Write(wt, byteorder.BigEndian); e != nil {
 		return e
 	}
 
@@ -71,85 +69,26 @@ func WriteHdr(wt io.Writer, r VerJsonHdr) error {
 	return nil
 }
 
+// Read decodes one value. Numbers are float64 with their exact bits, NaN and
+// Inf included, so that Write gives the same bytes back; see JSONValue before
+// marshalling them. It trusts the input: see DecodeOptions for files that
+// come from elsewhere.
 func Read(rd io.Reader) (interface{}, error) {
-	typ, e := byteorder.Uint8(rd)
-	if e != nil {
-		return nil, e
-	}
-
-	switch typ {
-	case NullT:
-		return nil, nil
-	case NumberT:
-		r, e := byteorder.Float64(rd, byteorder.BigEndian)
-		if math.IsNaN(r) {
-			return "____NaN____", e
-		} else if math.IsInf(r, 1) {
-			return "____+Inf____", e
-		} else if math.IsInf(r, -1) {
-			return "____-Inf____", e
-		}
-		return r, e
-	case BoolT:
-		return byteorder.Bool(rd)
-	case VarintT:
-		return byteorder.Varint(rd, byteorder.BigEndian)
-	case StringT:
-		return ReadString(rd, byteorder.BigEndian)
-	case ArrayT:
-		return ReadArray(rd)
-	case ObjectT:
-		return ReadObject(rd)
-	default:
-		return nil, errors.Errorf("unknown type %d", typ)
-	}
+	return newReader(rd, DecodeOptions{}).value()
 }
 
 func ReadArray(rd io.Reader) ([]interface{}, error) {
-	cnt, e := byteorder.UVarint(rd, byteorder.BigEndian)
-	if e != nil {
-		return nil, e
-	}
-
-	r := []interface{}{}
-
-	for i, c := 0, int(cnt); i < c; i++ {
-		value, e := Read(rd)
-		if e != nil {
-			return nil, e
-		}
-
-		r = append(r, value)
-	}
-
-	return r, nil
+	return newReader(rd, DecodeOptions{}).array(0)
 }
 
-func ReadObject(rd io.Reader) (map[String]interface{}, error) {
-	cnt, e := byteorder.UVarint(rd, byteorder.BigEndian)
-	if e != nil {
-		return nil, e
-	}
-
-	r := map[String]interface{}{}
-
-	for i, c := 0, int(cnt); i < c; i++ {
-		key, e := ReadString(rd, byteorder.BigEndian)
-		if e != nil {
-			return nil, e
-		}
-
-		value, e := Read(rd)
-		if e != nil {
-			return nil, e
-		}
-
-		r[key] = value
-	}
-
-	return r, nil
+func ReadObject(rd io.Reader) (Object, error) {
+	return newReader(rd, DecodeOptions{}).object(0)
 }
 
+// Write encodes anything. Object keeps its key order, while plain maps are
+// written with their keys sorted. A string, as DecodeJSON returns it, that is
+// ____NaN____, ____+Inf____ or ____-Inf____ is written as that number, while
+// a String, as Read returns it, is always a string.
 func Write(wt io.Writer, anything interface{}) error {
 	switch n := anything.(type) {
 	case nil:
@@ -201,32 +140,11 @@ func Write(wt io.Writer, anything interface{}) error {
 
 		return byteorder.PutBool(wt, n)
 	case String:
-		switch n {
-		case "____NaN____":
-			if e := byteorder.PutUint8(wt, NumberT); e != nil {
-				return e
-			}
-
-			return byteorder.PutFloat64(wt, byteorder.BigEndian, math.NaN())
-		case "____+Inf____":
-			if e := byteorder.PutUint8(wt, NumberT); e != nil {
-				return e
-			}
-
-			return byteorder.PutFloat64(wt, byteorder.BigEndian, math.Inf(1))
-		case "____-Inf____":
-			if e := byteorder.PutUint8(wt, NumberT); e != nil {
-				return e
-			}
-
-			return byteorder.PutFloat64(wt, byteorder.BigEndian, math.Inf(-1))
-		default:
-			if e := byteorder.PutUint8(wt, StringT); e != nil {
-				return e
-			}
-
-			return n.Write(wt, byteorder.BigEndian)
+		if e := byteorder.PutUint8(wt, StringT); e != nil {
+			return e
 		}
+
+		return n.Write(wt, byteorder.BigEndian)
 	case string:
 		switch n {
 		case "____NaN____":
@@ -261,18 +179,29 @@ func Write(wt io.Writer, anything interface{}) error {
 		}
 
 		return WriteArray(wt, n)
-	case map[String]interface{}:
+	case Object:
 		if e := byteorder.PutUint8(wt, ObjectT); e != nil {
 			return e
 		}
 
 		return WriteObject(wt, n)
+	case map[String]interface{}:
+		if e := byteorder.PutUint8(wt, ObjectT); e != nil {
+			return e
+		}
+
+		return WriteObject(wt, sortedObject(n))
 	case map[string]interface{}:
 		if e := byteorder.PutUint8(wt, ObjectT); e != nil {
 			return e
 		}
 
-		return writeobj(wt, n)
+		m := make(map[String]interface{}, len(n))
+		for k, v := range n {
+			m[String(k)] = v
+		}
+
+		return WriteObject(wt, sortedObject(m))
 	default:
 		return errors.Errorf("unknown type %+v", anything)
 	}
@@ -297,39 +226,18 @@ func WriteArray(wt io.Writer, array []interface{}) error {
 	return nil
 }
 
-func WriteObject(wt io.Writer, object map[String]interface{}) error {
+func WriteObject(wt io.Writer, object Object) error {
 	e := byteorder.PutUVarint(wt, byteorder.BigEndian, uint64(len(object)))
 	if e != nil {
 		return e
 	}
 
-	for k, v := range object {
-		if e := k.Write(wt, byteorder.BigEndian); e != nil {
-			return e
-		}
-
-		if e := Write(wt, v); e != nil {
-			return e
-		}
-	}
-
-	return nil
-}
-
-func writeobj(wt io.Writer, object map[string]interface{}) error {
-	e := byteorder.PutUVarint(wt, byteorder.BigEndian, uint64(len(object)))
-	if e != nil {
-		return e
-	}
-
-	for k, v := range object {
-		r := String(k)
-
-		if e := r.Write(wt, byteorder.BigEndian); e != nil {
+	for k := range object {
+		if e := object[k].Key.Write(wt, byteorder.BigEndian); e != nil {
 			return e
 		}
 
-		if e := Write(wt, v); e != nil {
+		if e := Write(wt, object[k].Value); e != nil {
 			return e
 		}
 	}
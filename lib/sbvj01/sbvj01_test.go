@@ -0,0 +1,127 @@
+package sbvj01
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+func randomValue(r *rand.Rand, depth int) interface{} {
+	n := 5
+	if depth > 0 {
+		n = 7
+	}
+
+	switch r.Intn(n) {
+	case 0:
+		return nil
+	case 1:
+		switch r.Intn(6) {
+		case 0:
+			return math.Inf(1 - 2*r.Intn(2))
+		case 4:
+			return math.NaN()
+		case 5:
+			// a NaN with its own payload
+			return math.Float64frombits(0x7ff8000000000000 | uint64(r.Intn(1<<20)))
+		case 1:
+			return float64(r.Intn(100))
+		default:
+			return r.NormFloat64() * 1e6
+		}
+	case 2:
+		return r.Intn(2) == 1
+	case 3:
+		return r.Int63() - r.Int63()
+	case 4:
+		switch r.Intn(8) {
+		case 0:
+			return String("____NaN____")
+		case 1:
+			return String("____-Inf____")
+		}
+		return String(fmt.Sprint("s", r.Intn(1000)))
+	case 5:
+		a := make([]interface{}, r.Intn(8))
+		for k := range a {
+			a[k] = randomValue(r, depth-1)
+		}
+		return a
+	default:
+		o := Object{}
+		for k, c := 0, r.Intn(12); k < c; k++ {
+			o.Set(String(fmt.Sprint("k", r.Intn(1000))), randomValue(r, depth-1))
+		}
+		return o
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 2000; i++ {
+		in := &bytes.Buffer{}
+		if e := Write(in, randomValue(r, 4)); e != nil {
+			t.Fatal(e)
+		}
+
+		v, e := Read(bytes.NewReader(in.Bytes()))
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		out := &bytes.Buffer{}
+		if e := Write(out, v); e != nil {
+			t.Fatal(e)
+		}
+
+		if !bytes.Equal(in.Bytes(), out.Bytes()) {
+			t.Fatalf("round trip %d differs:\n%x\n%x", i, in.Bytes(), out.Bytes())
+		}
+	}
+}
+
+func TestRoundTripSpecial(t *testing.T) {
+	for _, in := range [][]byte{
+		{NumberT, 0x7f, 0xf8, 0, 0, 0, 0, 0, 0},
+		{NumberT, 0xff, 0xf0, 0, 0, 0, 0, 0, 0},
+		append([]byte{StringT, 11}, "____NaN____"...),
+	} {
+		v, e := Read(bytes.NewReader(in))
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		out := &bytes.Buffer{}
+		if e := Write(out, v); e != nil {
+			t.Fatal(e)
+		}
+
+		if !bytes.Equal(in, out.Bytes()) {
+			t.Fatalf("round trip differs:\n%x\n%x", in, out.Bytes())
+		}
+	}
+}
+
+func TestObjectJSON(t *testing.T) {
+	src := `{"z":1,"a":{"y":[1.5,"x",null],"b":true},"m":{}}`
+
+	v, e := DecodeJSON(bytes.NewReader([]byte(src)))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	out, e := json.Marshal(v)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if string(out) != src {
+		t.Fatalf("expect %s, got %s", src, out)
+	}
+}
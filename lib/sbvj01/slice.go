@@ -0,0 +1,211 @@
+package sbvj01
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/xhebox/bstruct/byteorder"
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+// bufReader decodes straight from a byte slice, which is much faster than
+// going through an io.Reader, and shares the strings of repeated keys.
+type bufReader struct {
+	buf  []byte
+	off  int
+	keys map[string]String
+}
+
+// ReadBuf is Read for a value held in memory, and returns the number of
+// bytes it used. The result is the same as Read's.
+func ReadBuf(buf []byte) (interface{}, int, error) {
+	if len(buf) == 0 {
+		return nil, 0, io.EOF
+	}
+
+	d := &bufReader{buf: buf, keys: map[string]String{}}
+
+	r, e := d.value()
+	if e != nil {
+		return nil, d.off, e
+	}
+
+	return r, d.off, nil
+}
+
+// ReadFileBuf is ReadFile for a file held in memory.
+func ReadFileBuf(buf []byte, c Container) ([]Document, Container, error) {
+	if c == Auto {
+		c = VJ
+		if bytes.HasPrefix(buf, Magic) {
+			c = VJMagic
+		}
+	}
+
+	d := &bufReader{buf: buf, keys: map[string]String{}}
+
+	return readFile(d, c, ReadHdr, func(io.Reader) (interface{}, error) {
+		return d.value()
+	})
+}
+
+// Read lets the headers of a file be read through the same offset.
+func (d *bufReader) Read(p []byte) (int, error) {
+	if d.off >= len(d.buf) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, d.buf[d.off:])
+	d.off += n
+	return n, nil
+}
+
+func (d *bufReader) short() error {
+	return errors.Wrapf(io.ErrUnexpectedEOF, "at byte %d", d.off)
+}
+
+func (d *bufReader) uvarint() (uint64, error) {
+	n, l, e := byteorder.BigEndian.UVarint(d.buf[d.off:])
+	if e != nil {
+		if e == io.ErrUnexpectedEOF {
+			return 0, d.short()
+		}
+		return 0, errors.Wrapf(e, "at byte %d", d.off)
+	}
+
+	d.off += l
+	return n, nil
+}
+
+func (d *bufReader) bytes() ([]byte, error) {
+	n, e := d.uvarint()
+	if e != nil {
+		return nil, e
+	}
+
+	if n > uint64(len(d.buf)-d.off) {
+		return nil, d.short()
+	}
+
+	r := d.buf[d.off : d.off+int(n)]
+	d.off += int(n)
+	return r, nil
+}
+
+func (d *bufReader) key() (String, error) {
+	b, e := d.bytes()
+	if e != nil {
+		return "", e
+	}
+
+	// the conversion in the index does not allocate
+	if k, ok := d.keys[string(b)]; ok {
+		return k, nil
+	}
+
+	k := String(b)
+	d.keys[string(k)] = k
+	return k, nil
+}
+
+// count reads the element count of a container, and caps it by what the
+// remaining bytes can hold, each element taking at least size bytes.
+func (d *bufReader) count(size int) (int, error) {
+	n, e := d.uvarint()
+	if e != nil {
+		return 0, e
+	}
+
+	if n > uint64(len(d.buf)-d.off)/uint64(size) {
+		return 0, d.short()
+	}
+
+	return int(n), nil
+}
+
+func (d *bufReader) value() (interface{}, error) {
+	if d.off >= len(d.buf) {
+		return nil, d.short()
+	}
+
+	typ := d.buf[d.off]
+	d.off++
+
+	switch typ {
+	case NullT:
+		return nil, nil
+	case NumberT:
+		if len(d.buf)-d.off < 8 {
+			return nil, d.short()
+		}
+
+		r := byteorder.BigEndian.Float64(d.buf[d.off:])
+		d.off += 8
+		return r, nil
+	case BoolT:
+		if d.off >= len(d.buf) {
+			return nil, d.short()
+		}
+
+		d.off++
+		return d.buf[d.off-1] != 0, nil
+	case VarintT:
+		n, l, e := byteorder.BigEndian.Varint(d.buf[d.off:])
+		if e != nil {
+			if e == io.ErrUnexpectedEOF {
+				return nil, d.short()
+			}
+			return nil, errors.Wrapf(e, "at byte %d", d.off)
+		}
+
+		d.off += l
+		return n, nil
+	case StringT:
+		b, e := d.bytes()
+		if e != nil {
+			return nil, e
+		}
+
+		return String(b), nil
+	case ArrayT:
+		n, e := d.count(1)
+		if e != nil {
+			return nil, e
+		}
+
+		r := make([]interface{}, n)
+
+		for k := range r {
+			r[k], e = d.value()
+			if e != nil {
+				return nil, e
+			}
+		}
+
+		return r, nil
+	case ObjectT:
+		n, e := d.count(2)
+		if e != nil {
+			return nil, e
+		}
+
+		r := make(Object, n)
+
+		for k := range r {
+			r[k].Key, e = d.key()
+			if e != nil {
+				return nil, e
+			}
+
+			r[k].Value, e = d.value()
+			if e != nil {
+				return nil, e
+			}
+		}
+
+		return r, nil
+	default:
+		return nil, errors.Errorf("unknown type %d at byte %d", typ, d.off-1)
+	}
+}
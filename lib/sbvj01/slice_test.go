@@ -0,0 +1,169 @@
+package sbvj01
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+func TestReadBuf(t *testing.T) {
+	r := rand.New(rand.NewSource(11))
+
+	for i := 0; i < 200; i++ {
+		buf := &bytes.Buffer{}
+		if e := Write(buf, randomValue(r, 4)); e != nil {
+			t.Fatal(e)
+		}
+		data := buf.Bytes()
+
+		want, e := Read(bytes.NewReader(data))
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		got, n, e := ReadBuf(data)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		// NaN is not equal to itself, so compare the values by their bytes
+		w, g := &bytes.Buffer{}, &bytes.Buffer{}
+		Write(w, want)
+		Write(g, got)
+
+		if n != len(data) || !bytes.Equal(w.Bytes(), data) || !bytes.Equal(g.Bytes(), data) {
+			t.Fatalf("%d: read %d of %d bytes\n%#v\n%#v", i, n, len(data), want, got)
+		}
+
+		for cut := 0; cut < len(data); cut++ {
+			if _, _, e := ReadBuf(data[:cut]); e == nil {
+				t.Fatalf("%d: no error for %d of %d bytes", i, cut, len(data))
+			} else if c := errors.Cause(e); cut != 0 && c != io.ErrUnexpectedEOF {
+				t.Fatalf("%d: got %v", i, e)
+			}
+		}
+	}
+
+	// a count larger than the data must not be allocated
+	if _, _, e := ReadBuf([]byte{ArrayT, 0xff, 0xff, 0xff, 0xff, 0x7f}); errors.Cause(e) != io.ErrUnexpectedEOF {
+		t.Fatalf("got %v", e)
+	}
+
+	file := &bytes.Buffer{}
+	doc := Document{Header: VerJsonHdr{Id: "x", Versioned: true, Version: 2}, Body: Object{{Key: "a", Value: int64(1)}}}
+	if e := WriteFile(file, VJMagic, doc); e != nil {
+		t.Fatal(e)
+	}
+
+	docs, c, e := ReadFileBuf(file.Bytes(), Auto)
+	if e != nil || c != VJMagic || !reflect.DeepEqual(docs, []Document{doc}) {
+		t.Fatalf("got %+v %s %v", docs, c, e)
+	}
+}
+
+// playerLike resembles a .player file: a few large objects, and an
+// inventory of items sharing the same keys.
+func playerLike(r *rand.Rand) interface{} {
+	items := make([]interface{}, 0, 2000)
+	for i := 0; i < cap(items); i++ {
+		items = append(items, Object{
+			{Key: "name", Value: String(fmt.Sprintf("item%d", r.Intn(300)))},
+			{Key: "count", Value: int64(r.Intn(1000))},
+			{Key: "parameters", Value: Object{
+				{Key: "durabilityHit", Value: r.Float64()},
+				{Key: "shortdescription", Value: String("A thing")},
+				{Key: "colorIndex", Value: int64(r.Intn(12))},
+			}},
+		})
+	}
+
+	blueprints := make([]interface{}, 0, 3000)
+	for i := 0; i < cap(blueprints); i++ {
+		blueprints = append(blueprints, Object{
+			{Key: "name", Value: String(fmt.Sprintf("recipe%d", i))},
+			{Key: "count", Value: int64(1)},
+			{Key: "parameters", Value: Object{}},
+		})
+	}
+
+	return Object{
+		{Key: "uuid", Value: String("0123456789abcdef0123456789abcdef")},
+		{Key: "identity", Value: Object{
+			{Key: "name", Value: String("hero")},
+			{Key: "species", Value: String("human")},
+			{Key: "color", Value: []interface{}{int64(51), int64(117), int64(237), int64(255)}},
+		}},
+		{Key: "inventory", Value: Object{{Key: "bag", Value: items}}},
+		{Key: "blueprints", Value: Object{{Key: "known", Value: blueprints}}},
+	}
+}
+
+// sectorLike resembles the entities of a world sector.
+func sectorLike(r *rand.Rand) interface{} {
+	entities := make([]interface{}, 0, 5000)
+	for i := 0; i < cap(entities); i++ {
+		entities = append(entities, Object{
+			{Key: "type", Value: String("object")},
+			{Key: "name", Value: String(fmt.Sprintf("object%d", r.Intn(100)))},
+			{Key: "tilePosition", Value: []interface{}{int64(r.Intn(3000)), int64(r.Intn(1000))}},
+			{Key: "direction", Value: String("left")},
+			{Key: "orientationIndex", Value: int64(r.Intn(4))},
+			{Key: "interactive", Value: r.Intn(2) == 0},
+			{Key: "parameters", Value: Object{}},
+			{Key: "uniqueId", Value: nil},
+			{Key: "scriptStorage", Value: Object{
+				{Key: "health", Value: r.Float64() * 100},
+				{Key: "state", Value: String("idle")},
+			}},
+		})
+	}
+
+	return entities
+}
+
+func benchmarkRead(b *testing.B, v interface{}, fast bool) {
+	buf := &bytes.Buffer{}
+	if e := Write(buf, v); e != nil {
+		b.Fatal(e)
+	}
+	data := buf.Bytes()
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var e error
+		if fast {
+			_, _, e = ReadBuf(data)
+		} else {
+			_, e = Read(bytes.NewReader(data))
+		}
+
+		if e != nil {
+			b.Fatal(e)
+		}
+	}
+}
+
+func BenchmarkReadPlayer(b *testing.B) {
+	benchmarkRead(b, playerLike(rand.New(rand.NewSource(1))), false)
+}
+
+func BenchmarkReadBufPlayer(b *testing.B) {
+	benchmarkRead(b, playerLike(rand.New(rand.NewSource(1))), true)
+}
+
+func BenchmarkReadSector(b *testing.B) {
+	benchmarkRead(b, sectorLike(rand.New(rand.NewSource(1))), false)
+}
+
+func BenchmarkReadBufSector(b *testing.B) {
+	benchmarkRead(b, sectorLike(rand.New(rand.NewSource(1))), true)
+}
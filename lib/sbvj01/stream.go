@@ -0,0 +1,499 @@
+package sbvj01
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/xhebox/bstruct/byteorder"
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+type TokenKind byte
+
+const (
+	BeginToken  TokenKind = iota + 1 // Type is ArrayT or ObjectT, Len the count
+	EndToken                         // Type is ArrayT or ObjectT
+	KeyToken                         // Key is set
+	ScalarToken                      // Type is NullT to StringT, Value is set
+)
+
+// Token is one step of a streamed document. Scalar values are nil, float64,
+// bool, int64 or String, matching Type exactly; NaN and Inf are kept as
+// float64.
+type Token struct {
+	Kind  TokenKind
+	Type  byte
+	Len   int
+	Key   String
+	Value interface{}
+}
+
+type frame struct {
+	typ    byte
+	remain uint64
+	key    bool // an object expects a key next
+}
+
+// Decoder reads a versioned json document token by token, without building
+// it in memory. Several values may follow each other in the stream.
+type Decoder struct {
+	rd    *bufio.Reader
+	off   int64
+	max   uint64
+	stack []frame
+}
+
+func NewDecoder(rd io.Reader) *Decoder {
+	b, ok := rd.(*bufio.Reader)
+	if !ok {
+		b = bufio.NewReader(rd)
+	}
+
+	return &Decoder{rd: b, max: MaxLength(rd)}
+}
+
+// MaxLength returns the length limit of the reader given to NewDecoder, see
+// LimitLength.
+func (d *Decoder) MaxLength() uint64 {
+	return d.max
+}
+
+// Offset returns the number of bytes consumed so far.
+func (d *Decoder) Offset() int64 {
+	return d.off
+}
+
+// Depth returns the number of containers currently open.
+func (d *Decoder) Depth() int {
+	return len(d.stack)
+}
+
+func (d *Decoder) Read(p []byte) (int, error) {
+	n, e := d.rd.Read(p)
+	d.off += int64(n)
+	return n, e
+}
+
+func (d *Decoder) ReadByte() (byte, error) {
+	c, e := d.rd.ReadByte()
+	if e == nil {
+		d.off++
+	}
+	return c, e
+}
+
+func (d *Decoder) discard(n uint64) error {
+	for n > 0 {
+		c := n
+		if c > 1<<30 {
+			c = 1 << 30
+		}
+
+		m, e := d.rd.Discard(int(c))
+		d.off += int64(m)
+		if e != nil {
+			return e
+		}
+
+		n -= uint64(m)
+	}
+
+	return nil
+}
+
+// next returns what the following token is, consuming the key or the end of
+// a container when that is the case. ok is false if a value comes next.
+func (d *Decoder) next() (tok Token, ok bool, e error) {
+	if len(d.stack) == 0 {
+		return tok, false, nil
+	}
+
+	top := &d.stack[len(d.stack)-1]
+
+	if top.remain == 0 {
+		d.stack = d.stack[:len(d.stack)-1]
+		return Token{Kind: EndToken, Type: top.typ}, true, nil
+	}
+
+	if top.typ == ObjectT && top.key {
+		key, e := ReadString(d, byteorder.BigEndian)
+		if e != nil {
+			return tok, false, unexpected(e)
+		}
+
+		top.key = false
+		return Token{Kind: KeyToken, Key: key}, true, nil
+	}
+
+	top.remain--
+	top.key = true
+	return tok, false, nil
+}
+
+func unexpected(e error) error {
+	if e == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return e
+}
+
+// Token returns the next token, or io.EOF once the stream ends between
+// values.
+func (d *Decoder) Token() (Token, error) {
+	tok, ok, e := d.next()
+	if ok || e != nil {
+		return tok, e
+	}
+
+	top := len(d.stack) == 0
+
+	typ, e := d.ReadByte()
+	if e != nil {
+		if top && e == io.EOF {
+			return tok, e
+		}
+
+		return tok, unexpected(e)
+	}
+
+	tok, e = d.value(typ)
+	return tok, unexpected(e)
+}
+
+func (d *Decoder) value(typ byte) (Token, error) {
+	tok := Token{Kind: ScalarToken, Type: typ}
+
+	var e error
+
+	switch typ {
+	case NullT:
+	case NumberT:
+		tok.Value, e = byteorder.Float64(d, byteorder.BigEndian)
+	case BoolT:
+		tok.Value, e = byteorder.Bool(d)
+	case VarintT:
+		tok.Value, e = byteorder.Varint(d, byteorder.BigEndian)
+	case StringT:
+		tok.Value, e = ReadString(d, byteorder.BigEndian)
+	case ArrayT, ObjectT:
+		cnt, e := byteorder.UVarint(d, byteorder.BigEndian)
+		if e != nil {
+			return tok, e
+		}
+
+		if e := CheckLength(d, cnt); e != nil {
+			return tok, e
+		}
+
+		tok.Kind = BeginToken
+		tok.Len = int(cnt)
+		d.stack = append(d.stack, frame{typ: typ, remain: cnt, key: true})
+	default:
+		return tok, errors.Errorf("unknown type %d at byte %d", typ, d.off-1)
+	}
+
+	return tok, e
+}
+
+// Skip discards the next value without decoding it. If a key comes next, the
+// key is skipped along with its value. Skip does nothing at the end of a
+// container.
+func (d *Decoder) Skip() error {
+	if len(d.stack) != 0 {
+		top := &d.stack[len(d.stack)-1]
+
+		if top.remain == 0 {
+			return nil
+		}
+
+		if top.typ == ObjectT && top.key {
+			if e := d.skipString(); e != nil {
+				return e
+			}
+		}
+
+		top.remain--
+		top.key = true
+	}
+
+	typ, e := d.ReadByte()
+	if e != nil {
+		return unexpected(e)
+	}
+
+	return unexpected(d.skip(typ))
+}
+
+func (d *Decoder) skipString() error {
+	l, e := byteorder.UVarint(d, byteorder.BigEndian)
+	if e != nil {
+		return unexpected(e)
+	}
+
+	return unexpected(d.discard(l))
+}
+
+// skip discards a value of type typ. Containers go on the stack like Token
+// puts them, so that deep nesting does not recurse.
+func (d *Decoder) skip(typ byte) error {
+	base := len(d.stack)
+
+	e := d.skipValues(typ, base)
+	if e != nil {
+		d.stack = d.stack[:base]
+	}
+
+	return e
+}
+
+func (d *Decoder) skipValues(typ byte, base int) error {
+	for {
+		switch typ {
+		case NullT:
+		case NumberT:
+			if e := d.discard(8); e != nil {
+				return e
+			}
+		case BoolT:
+			if e := d.discard(1); e != nil {
+				return e
+			}
+		case VarintT:
+			if _, e := byteorder.UVarint(d, byteorder.BigEndian); e != nil {
+				return e
+			}
+		case StringT:
+			if e := d.skipString(); e != nil {
+				return e
+			}
+		case ArrayT, ObjectT:
+			cnt, e := byteorder.UVarint(d, byteorder.BigEndian)
+			if e != nil {
+				return e
+			}
+
+			d.stack = append(d.stack, frame{typ: typ, remain: cnt})
+		default:
+			return errors.Errorf("unknown type %d at byte %d", typ, d.off-1)
+		}
+
+		// close the containers that are done, up to the next value
+		for {
+			if len(d.stack) == base {
+				return nil
+			}
+
+			top := &d.stack[len(d.stack)-1]
+			if top.remain != 0 {
+				break
+			}
+
+			d.stack = d.stack[:len(d.stack)-1]
+		}
+
+		top := &d.stack[len(d.stack)-1]
+		if top.typ == ObjectT {
+			if e := d.skipString(); e != nil {
+				return e
+			}
+		}
+		top.remain--
+
+		var e error
+		if typ, e = d.ReadByte(); e != nil {
+			return e
+		}
+	}
+}
+
+// Decode reads the next value as a whole, like Read. If a key comes next, it
+// is consumed first.
+func (d *Decoder) Decode() (interface{}, error) {
+	tok, ok, e := d.next()
+	if e != nil {
+		return nil, e
+	}
+
+	if ok {
+		if tok.Kind == EndToken {
+			return nil, errors.New("no value left in the container")
+		}
+
+		if _, ok, e = d.next(); ok || e != nil {
+			return nil, e
+		}
+	}
+
+	return Read(d)
+}
+
+// Encoder writes a versioned json document token by token. Containers take
+// their element count up front, as the format requires, and End checks it.
+type Encoder struct {
+	wt    io.Writer
+	stack []frame
+}
+
+func NewEncoder(wt io.Writer) *Encoder {
+	return &Encoder{wt: wt}
+}
+
+// value accounts for one value about to be written in the current container.
+func (e *Encoder) value() error {
+	if len(e.stack) == 0 {
+		return nil
+	}
+
+	top := &e.stack[len(e.stack)-1]
+
+	if top.remain == 0 {
+		return errors.New("too many elements")
+	}
+
+	if top.typ == ObjectT && top.key {
+		return errors.New("expect a key")
+	}
+
+	top.remain--
+	top.key = true
+	return nil
+}
+
+func (e *Encoder) scalar(typ byte) error {
+	if err := e.value(); err != nil {
+		return err
+	}
+
+	return byteorder.PutUint8(e.wt, typ)
+}
+
+func (e *Encoder) WriteNull() error {
+	return e.scalar(NullT)
+}
+
+func (e *Encoder) WriteNumber(f float64) error {
+	if err := e.scalar(NumberT); err != nil {
+		return err
+	}
+
+	return byteorder.PutFloat64(e.wt, byteorder.BigEndian, f)
+}
+
+func (e *Encoder) WriteBool(b bool) error {
+	if err := e.scalar(BoolT); err != nil {
+		return err
+	}
+
+	return byteorder.PutBool(e.wt, b)
+}
+
+func (e *Encoder) WriteVarint(i int64) error {
+	if err := e.scalar(VarintT); err != nil {
+		return err
+	}
+
+	return byteorder.PutVarint(e.wt, byteorder.BigEndian, i)
+}
+
+func (e *Encoder) WriteString(s String) error {
+	if err := e.scalar(StringT); err != nil {
+		return err
+	}
+
+	return s.Write(e.wt, byteorder.BigEndian)
+}
+
+func (e *Encoder) WriteKey(k String) error {
+	if len(e.stack) == 0 || e.stack[len(e.stack)-1].typ != ObjectT {
+		return errors.New("key outside of an object")
+	}
+
+	top := &e.stack[len(e.stack)-1]
+
+	if !top.key {
+		return errors.New("expect a value")
+	}
+
+	if top.remain == 0 {
+		return errors.New("too many elements")
+	}
+
+	top.key = false
+	return k.Write(e.wt, byteorder.BigEndian)
+}
+
+func (e *Encoder) begin(typ byte, n int) error {
+	if err := e.scalar(typ); err != nil {
+		return err
+	}
+
+	if err := byteorder.PutUVarint(e.wt, byteorder.BigEndian, uint64(n)); err != nil {
+		return err
+	}
+
+	e.stack = append(e.stack, frame{typ: typ, remain: uint64(n), key: true})
+	return nil
+}
+
+func (e *Encoder) BeginArray(n int) error {
+	return e.begin(ArrayT, n)
+}
+
+func (e *Encoder) BeginObject(n int) error {
+	return e.begin(ObjectT, n)
+}
+
+// End closes the innermost container.
+func (e *Encoder) End() error {
+	if len(e.stack) == 0 {
+		return errors.New("no container to end")
+	}
+
+	top := e.stack[len(e.stack)-1]
+
+	if top.remain != 0 || !top.key {
+		return errors.Errorf("%d elements missing", top.remain)
+	}
+
+	e.stack = e.stack[:len(e.stack)-1]
+	return nil
+}
+
+// Encode writes a whole value, like Write.
+func (e *Encoder) Encode(v interface{}) error {
+	if err := e.value(); err != nil {
+		return err
+	}
+
+	return Write(e.wt, v)
+}
+
+// WriteToken writes a token as returned by Decoder.Token, so that a stream
+// can be filtered while copied.
+func (e *Encoder) WriteToken(t Token) error {
+	switch t.Kind {
+	case BeginToken:
+		return e.begin(t.Type, t.Len)
+	case EndToken:
+		return e.End()
+	case KeyToken:
+		return e.WriteKey(t.Key)
+	case ScalarToken:
+		switch t.Type {
+		case NullT:
+			return e.WriteNull()
+		case NumberT:
+			return e.WriteNumber(t.Value.(float64))
+		case BoolT:
+			return e.WriteBool(t.Value.(bool))
+		case VarintT:
+			return e.WriteVarint(t.Value.(int64))
+		case StringT:
+			return e.WriteString(t.Value.(String))
+		}
+	}
+
+	return errors.Errorf("invalid token %+v", t)
+}
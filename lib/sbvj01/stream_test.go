@@ -0,0 +1,158 @@
+package sbvj01
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+func TestStreamCopy(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+
+	for i := 0; i < 500; i++ {
+		in := &bytes.Buffer{}
+		for k := 0; k < 3; k++ {
+			if e := Write(in, randomValue(r, 4)); e != nil {
+				t.Fatal(e)
+			}
+		}
+
+		out := &bytes.Buffer{}
+		dec := NewDecoder(bytes.NewReader(in.Bytes()))
+		enc := NewEncoder(out)
+
+		for {
+			tok, e := dec.Token()
+			if e == io.EOF {
+				break
+			}
+			if e != nil {
+				t.Fatal(e)
+			}
+
+			if e := enc.WriteToken(tok); e != nil {
+				t.Fatal(e)
+			}
+		}
+
+		if !bytes.Equal(in.Bytes(), out.Bytes()) {
+			t.Fatalf("copy %d differs:\n%x\n%x", i, in.Bytes(), out.Bytes())
+		}
+
+		if dec.Offset() != int64(in.Len()) {
+			t.Fatalf("expect offset %d, got %d", in.Len(), dec.Offset())
+		}
+	}
+}
+
+func TestStreamSkip(t *testing.T) {
+	in := &bytes.Buffer{}
+	Write(in, Object{
+		{Key: "big", Value: []interface{}{Object{{Key: "x", Value: String("yyy")}}, 1.5, nil}},
+		{Key: "small", Value: int64(-5)},
+		{Key: "after", Value: true},
+	})
+
+	dec := NewDecoder(bytes.NewReader(in.Bytes()))
+
+	tok, e := dec.Token()
+	if e != nil || tok.Kind != BeginToken || tok.Type != ObjectT || tok.Len != 3 {
+		t.Fatalf("unexpected %+v, %v", tok, e)
+	}
+
+	// skips "big" and its value
+	if e := dec.Skip(); e != nil {
+		t.Fatal(e)
+	}
+
+	tok, e = dec.Token()
+	if e != nil || tok.Kind != KeyToken || tok.Key != "small" {
+		t.Fatalf("unexpected %+v, %v", tok, e)
+	}
+
+	tok, e = dec.Token()
+	if e != nil || tok.Kind != ScalarToken || tok.Type != VarintT || tok.Value != int64(-5) {
+		t.Fatalf("unexpected %+v, %v", tok, e)
+	}
+
+	v, e := dec.Decode()
+	if e != nil || v != true {
+		t.Fatalf("unexpected %+v, %v", v, e)
+	}
+
+	tok, e = dec.Token()
+	if e != nil || tok.Kind != EndToken || tok.Type != ObjectT {
+		t.Fatalf("unexpected %+v, %v", tok, e)
+	}
+
+	if _, e := dec.Token(); e != io.EOF {
+		t.Fatalf("expect EOF, got %v", e)
+	}
+}
+
+func TestStreamSkipDeep(t *testing.T) {
+	// nested far deeper than recursion would allow, then a value after it
+	in := bytes.Repeat([]byte{ArrayT, 1}, 1<<20)
+	in = append(in, ObjectT, 1, 1, 'k', VarintT, 0, BoolT, 1)
+
+	dec := NewDecoder(bytes.NewReader(in))
+	if e := dec.Skip(); e != nil {
+		t.Fatal(e)
+	}
+
+	if dec.Depth() != 0 || dec.Offset() != int64(len(in)-2) {
+		t.Fatalf("depth %d, offset %d", dec.Depth(), dec.Offset())
+	}
+
+	if v, e := dec.Decode(); e != nil || v != true {
+		t.Fatalf("unexpected %+v, %v", v, e)
+	}
+
+	if e := NewDecoder(bytes.NewReader(in[:len(in)-3])).Skip(); e != io.ErrUnexpectedEOF {
+		t.Fatalf("expect a short value, got %v", e)
+	}
+
+	r := rand.New(rand.NewSource(3))
+	for i := 0; i < 100; i++ {
+		buf := &bytes.Buffer{}
+		if e := Write(buf, randomValue(r, 4)); e != nil {
+			t.Fatal(e)
+		}
+
+		dec := NewDecoder(bytes.NewReader(buf.Bytes()))
+		if e := dec.Skip(); e != nil || dec.Offset() != int64(buf.Len()) {
+			t.Fatalf("skip %d: offset %d of %d, %v", i, dec.Offset(), buf.Len(), e)
+		}
+	}
+}
+
+func TestEncoderCount(t *testing.T) {
+	enc := NewEncoder(&bytes.Buffer{})
+
+	if e := enc.BeginObject(1); e != nil {
+		t.Fatal(e)
+	}
+
+	if e := enc.WriteVarint(1); e == nil {
+		t.Fatal("expect a key first")
+	}
+
+	if e := enc.WriteKey("a"); e != nil {
+		t.Fatal(e)
+	}
+
+	if e := enc.End(); e == nil {
+		t.Fatal("expect a missing value")
+	}
+
+	if e := enc.WriteBool(true); e != nil {
+		t.Fatal(e)
+	}
+
+	if e := enc.End(); e != nil {
+		t.Fatal(e)
+	}
+}
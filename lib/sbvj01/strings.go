@@ -0,0 +1,205 @@
+package sbvj01
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+// EncodeOptions changes what the writers accept. The zero value writes
+// everything as it is, like Write.
+type EncodeOptions struct {
+	// Strings applies to every string, object key and header id.
+	Strings StringPolicy
+}
+
+// InvalidString is a string that is not valid utf-8, located by the json
+// pointer of its value, or of its key when Key is set.
+type InvalidString struct {
+	Path   string
+	Key    bool
+	Offset int
+}
+
+// InvalidStrings lists the strings of v that the game would refuse.
+func InvalidStrings(v interface{}) []InvalidString {
+	r := []InvalidString{}
+
+	walkStrings(v, "", func(path string, key bool, s String) (String, error) {
+		if i := InvalidUTF8(string(s)); i != -1 {
+			r = append(r, InvalidString{Path: path, Key: key, Offset: i})
+		}
+		return s, nil
+	})
+
+	return r
+}
+
+// walkStrings calls fn with every string and object key of v, and returns v
+// with the strings fn returns. Containers are copied only when they change.
+func walkStrings(v interface{}, path string, fn func(path string, key bool, s String) (String, error)) (interface{}, error) {
+	switch n := v.(type) {
+	case String:
+		return fn(path, false, n)
+	case string:
+		s, e := fn(path, false, String(n))
+		return string(s), e
+	case []interface{}:
+		var r []interface{}
+
+		for k := range n {
+			m, e := walkStrings(n[k], path+"/"+strconv.Itoa(k), fn)
+			if e != nil {
+				return nil, e
+			}
+
+			if r == nil && !sameString(m, n[k]) {
+				r = make([]interface{}, len(n))
+				copy(r, n)
+			}
+
+			if r != nil {
+				r[k] = m
+			}
+		}
+
+		if r == nil {
+			return n, nil
+		}
+		return r, nil
+	}
+
+	o, ok := asObject(v)
+	if !ok {
+		return v, nil
+	}
+
+	var r Object
+
+	for k := range o {
+		p := path + "/" + escapePointer(o[k].Key)
+
+		key, e := fn(p, true, o[k].Key)
+		if e != nil {
+			return nil, e
+		}
+
+		m, e := walkStrings(o[k].Value, p, fn)
+		if e != nil {
+			return nil, e
+		}
+
+		if r == nil && (key != o[k].Key || !sameString(m, o[k].Value)) {
+			r = make(Object, len(o))
+			copy(r, o)
+		}
+
+		if r != nil {
+			r[k] = Pair{Key: key, Value: m}
+		}
+	}
+
+	if r == nil {
+		return v, nil
+	}
+	return r, nil
+}
+
+// sameString tells if a walk, like walkStrings, left a value unchanged, which
+// it returns as is.
+func sameString(a, b interface{}) bool {
+	switch x := a.(type) {
+	case String:
+		y, ok := b.(String)
+		return ok && x == y
+	case string:
+		y, ok := b.(string)
+		return ok && x == y
+	case []interface{}:
+		y, ok := b.([]interface{})
+		return ok && len(x) == len(y) && (len(x) == 0 || &x[0] == &y[0])
+	case Object:
+		y, ok := b.(Object)
+		return ok && len(x) == len(y) && (len(x) == 0 || &x[0] == &y[0])
+	}
+
+	return true
+}
+
+func (o EncodeOptions) apply(v interface{}) (interface{}, error) {
+	if o.Strings == Passthrough {
+		return v, nil
+	}
+
+	return walkStrings(v, "", func(path string, key bool, s String) (String, error) {
+		r, e := o.Strings.Apply(s)
+		if e != nil {
+			if key {
+				return r, errors.Wrapf(e, "key %s", path)
+			}
+			return r, errors.Wrapf(e, "string %s", path)
+		}
+		return r, nil
+	})
+}
+
+// Write is Write with the strings of anything going through the policy
+// first. anything is not modified.
+func (o EncodeOptions) Write(wt io.Writer, anything interface{}) error {
+	v, e := o.apply(anything)
+	if e != nil {
+		return e
+	}
+
+	return Write(wt, v)
+}
+
+// WriteTagged is WriteTagged with the policy applied like Write.
+func (o EncodeOptions) WriteTagged(wt io.Writer, anything interface{}) error {
+	v, e := o.apply(anything)
+	if e != nil {
+		return e
+	}
+
+	return WriteTagged(wt, v)
+}
+
+// WriteHdr is WriteHdr with the policy applied to the id.
+func (o EncodeOptions) WriteHdr(wt io.Writer, r VerJsonHdr) error {
+	id, e := o.Strings.Apply(r.Id)
+	if e != nil {
+		return errors.Wrap(e, "header id")
+	}
+
+	r.Id = id
+	return WriteHdr(wt, r)
+}
+
+// WriteFile is WriteFile with the policy applied to every document.
+func (o EncodeOptions) WriteFile(wt io.Writer, c Container, docs ...Document) error {
+	return o.writeFile(wt, c, docs, o.Write)
+}
+
+// WriteFileTagged is WriteFileTagged with the policy applied to every
+// document.
+func (o EncodeOptions) WriteFileTagged(wt io.Writer, c Container, docs ...Document) error {
+	return o.writeFile(wt, c, docs, o.WriteTagged)
+}
+
+func (o EncodeOptions) writeFile(wt io.Writer, c Container, docs []Document, write func(io.Writer, interface{}) error) error {
+	r := make([]Document, len(docs))
+
+	for k := range docs {
+		id, e := o.Strings.Apply(docs[k].Header.Id)
+		if e != nil {
+			return errors.Wrapf(e, "header id of document %d", k)
+		}
+
+		r[k] = docs[k]
+		r[k].Header.Id = id
+	}
+
+	return writeFile(wt, c, r, write)
+}
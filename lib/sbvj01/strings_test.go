@@ -0,0 +1,104 @@
+package sbvj01
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+func TestEncodeOptions(t *testing.T) {
+	v := Object{
+		{Key: "name", Value: String("bad\xc3")},
+		{Key: "k\xff", Value: []interface{}{String("fine"), "go\x80"}},
+		{Key: "nan", Value: "____NaN____"},
+	}
+
+	want := []InvalidString{
+		{Path: "/name", Offset: 3},
+		{Path: "/k\xff", Key: true, Offset: 1},
+		{Path: "/k\xff/1", Offset: 2},
+	}
+	if got := InvalidStrings(v); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+
+	doc := Document{Header: VerJsonHdr{Id: "Player"}, Body: v}
+
+	plain := &bytes.Buffer{}
+	if e := WriteFile(plain, VJ, doc); e != nil {
+		t.Fatal(e)
+	}
+
+	pass := &bytes.Buffer{}
+	if e := (EncodeOptions{}).WriteFile(pass, VJ, doc); e != nil || !bytes.Equal(pass.Bytes(), plain.Bytes()) {
+		t.Fatalf("passthrough differs: %v", e)
+	}
+
+	e := EncodeOptions{Strings: Reject}.WriteFile(&bytes.Buffer{}, VJ, doc)
+	if u, ok := errors.Cause(e).(*InvalidUTF8Error); !ok || u.Offset != 3 || !strings.Contains(e.Error(), "/name") {
+		t.Fatalf("reject: %v", e)
+	}
+
+	e = EncodeOptions{Strings: Reject}.WriteFile(&bytes.Buffer{}, VJ, Document{Header: VerJsonHdr{Id: "\xff"}})
+	if _, ok := errors.Cause(e).(*InvalidUTF8Error); !ok {
+		t.Fatalf("reject id: %v", e)
+	}
+
+	buf := &bytes.Buffer{}
+	if e := (EncodeOptions{Strings: Replace}).Write(buf, v); e != nil {
+		t.Fatal(e)
+	}
+
+	r, e := Read(buf)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	fixed := Object{
+		{Key: "name", Value: String("badI")},
+		{Key: "kI", Value: []interface{}{String("fine"), String("goI")}},
+		{Key: "nan", Value: "____NaN____"},
+	}
+	if !reflect.DeepEqual(JSONValue(r), fixed) {
+		t.Fatalf("replace: got %#v", r)
+	}
+
+	if len(InvalidStrings(r)) != 0 {
+		t.Fatal("replaced strings still invalid")
+	}
+
+	if v[0].Value != String("bad\xc3") || v[1].Key != "k\xff" {
+		t.Fatal("input modified")
+	}
+}
+
+func TestDecodeRawJSON(t *testing.T) {
+	data := []byte("{\"k\xff\": [\"ab\xe4\", \"\\u00e9\xc3\", \"\\ud83d\\ude00\", \"\\ud83d\xf0\", \"fine\"]}")
+
+	want := Object{{Key: "k\xff", Value: []interface{}{"ab\xe4", "\u00e9\xc3", "\U0001f600", "\ufffd\xf0", "fine"}}}
+
+	for _, decode := range []func([]byte) (interface{}, error){DecodeRawJSON, DecodeRelaxedRawJSON} {
+		v, e := decode(data)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		if !reflect.DeepEqual(v, want) {
+			t.Fatalf("got %#v", v)
+		}
+	}
+
+	// encoding/json replaces them
+	v, e := DecodeJSON(bytes.NewReader(data))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if len(InvalidStrings(v)) != 0 {
+		t.Fatal("DecodeJSON kept invalid utf-8")
+	}
+}
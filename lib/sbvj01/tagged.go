@@ -0,0 +1,255 @@
+package sbvj01
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xhebox/bstruct/byteorder"
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+// Tagged json keeps the exact wire type of every value, so that it converts
+// back to the same bytes:
+//
+// Numbers are always written with a fraction or an exponent, like 3.0, and
+// varints never are. NaN and Inf become the strings ____NaN____,
+// ____+Inf____ and ____-Inf____, and a NaN with unusual bits becomes
+// ____NaN:<hex bits>____. Any real string starting with ____ is escaped by
+// prepending ____=, so it can not be taken for one of those.
+const (
+	tagPrefix = "____"
+	tagEscape = "____="
+	tagNaN    = "____NaN____"
+	tagPosInf = "____+Inf____"
+	tagNegInf = "____-Inf____"
+)
+
+var canonicalNaN = math.Float64bits(math.NaN())
+
+func formatTagged(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		if b := math.Float64bits(f); b != canonicalNaN {
+			return tagPrefix + "NaN:" + strconv.FormatUint(b, 16) + tagPrefix
+		}
+		return tagNaN
+	case math.IsInf(f, 1):
+		return tagPosInf
+	case math.IsInf(f, -1):
+		return tagNegInf
+	}
+
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+
+	return s
+}
+
+func parseTagged(s string) (float64, error) {
+	switch s {
+	case tagNaN:
+		return math.NaN(), nil
+	case tagPosInf:
+		return math.Inf(1), nil
+	case tagNegInf:
+		return math.Inf(-1), nil
+	}
+
+	if strings.HasPrefix(s, tagPrefix+"NaN:") && strings.HasSuffix(s, tagPrefix) {
+		b, e := strconv.ParseUint(s[len(tagPrefix)+4:len(s)-len(tagPrefix)], 16, 64)
+		if e == nil && math.IsNaN(math.Float64frombits(b)) {
+			return math.Float64frombits(b), nil
+		}
+	}
+
+	return 0, errors.Errorf("invalid tagged string %q, escape it as %q", s, tagEscape+s)
+}
+
+// ReadTagged is Read, except that the result is meant to be marshalled as
+// tagged json: numbers and varints are json.Number, and strings are escaped.
+func ReadTagged(rd io.Reader) (interface{}, error) {
+	typ, e := byteorder.Uint8(rd)
+	if e != nil {
+		return nil, e
+	}
+
+	switch typ {
+	case NullT:
+		return nil, nil
+	case NumberT:
+		r, e := byteorder.Float64(rd, byteorder.BigEndian)
+		if e != nil {
+			return nil, e
+		}
+
+		s := formatTagged(r)
+		if strings.HasPrefix(s, tagPrefix) {
+			return String(s), nil
+		}
+
+		return json.Number(s), nil
+	case BoolT:
+		return byteorder.Bool(rd)
+	case VarintT:
+		r, e := byteorder.Varint(rd, byteorder.BigEndian)
+		return json.Number(strconv.FormatInt(r, 10)), e
+	case StringT:
+		r, e := ReadString(rd, byteorder.BigEndian)
+		if strings.HasPrefix(string(r), tagPrefix) {
+			r = tagEscape + r
+		}
+		return r, e
+	case ArrayT:
+		cnt, e := byteorder.UVarint(rd, byteorder.BigEndian)
+		if e != nil {
+			return nil, e
+		}
+
+		if e := CheckLength(rd, cnt); e != nil {
+			return nil, e
+		}
+
+		r := []interface{}{}
+
+		for i, c := 0, int(cnt); i < c; i++ {
+			value, e := ReadTagged(rd)
+			if e != nil {
+				return nil, e
+			}
+
+			r = append(r, value)
+		}
+
+		return r, nil
+	case ObjectT:
+		cnt, e := byteorder.UVarint(rd, byteorder.BigEndian)
+		if e != nil {
+			return nil, e
+		}
+
+		if e := CheckLength(rd, cnt); e != nil {
+			return nil, e
+		}
+
+		r := Object{}
+
+		for i, c := 0, int(cnt); i < c; i++ {
+			key, e := ReadString(rd, byteorder.BigEndian)
+			if e != nil {
+				return nil, e
+			}
+
+			value, e := ReadTagged(rd)
+			if e != nil {
+				return nil, e
+			}
+
+			r = append(r, Pair{Key: key, Value: value})
+		}
+
+		return r, nil
+	default:
+		return nil, errors.Errorf("unknown type %d", typ)
+	}
+}
+
+// WriteTagged is Write for values decoded from tagged json, e.g. by
+// DecodeJSON. It undoes what ReadTagged does.
+func WriteTagged(wt io.Writer, anything interface{}) error {
+	switch n := anything.(type) {
+	case json.Number:
+		if strings.ContainsAny(string(n), ".eE") {
+			f, e := strconv.ParseFloat(string(n), 64)
+			if e != nil {
+				return e
+			}
+
+			return Write(wt, f)
+		}
+
+		i, e := strconv.ParseInt(string(n), 10, 64)
+		if e != nil {
+			return e
+		}
+
+		return Write(wt, i)
+	case string:
+		return WriteTagged(wt, String(n))
+	case String:
+		if strings.HasPrefix(string(n), tagEscape) {
+			n = n[len(tagEscape):]
+		} else if strings.HasPrefix(string(n), tagPrefix) {
+			f, e := parseTagged(string(n))
+			if e != nil {
+				return e
+			}
+
+			return Write(wt, f)
+		}
+
+		if e := byteorder.PutUint8(wt, StringT); e != nil {
+			return e
+		}
+
+		return n.Write(wt, byteorder.BigEndian)
+	case []interface{}:
+		if e := byteorder.PutUint8(wt, ArrayT); e != nil {
+			return e
+		}
+
+		if e := byteorder.PutUVarint(wt, byteorder.BigEndian, uint64(len(n))); e != nil {
+			return e
+		}
+
+		for k := range n {
+			if e := WriteTagged(wt, n[k]); e != nil {
+				return e
+			}
+		}
+
+		return nil
+	case map[string]interface{}:
+		m := make(map[String]interface{}, len(n))
+		for k, v := range n {
+			m[String(k)] = v
+		}
+
+		return WriteTagged(wt, sortedObject(m))
+	case map[String]interface{}:
+		return WriteTagged(wt, sortedObject(n))
+	case Object:
+		if e := byteorder.PutUint8(wt, ObjectT); e != nil {
+			return e
+		}
+
+		if e := byteorder.PutUVarint(wt, byteorder.BigEndian, uint64(len(n))); e != nil {
+			return e
+		}
+
+		for k := range n {
+			if e := n[k].Key.Write(wt, byteorder.BigEndian); e != nil {
+				return e
+			}
+
+			if e := WriteTagged(wt, n[k].Value); e != nil {
+				return e
+			}
+		}
+
+		return nil
+	case float64:
+		if e := byteorder.PutUint8(wt, NumberT); e != nil {
+			return e
+		}
+
+		return byteorder.PutFloat64(wt, byteorder.BigEndian, n)
+	default:
+		return Write(wt, anything)
+	}
+}
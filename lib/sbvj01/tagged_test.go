@@ -0,0 +1,78 @@
+package sbvj01
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+func taggedRoundTrip(t *testing.T, v interface{}) []byte {
+	in := &bytes.Buffer{}
+
+	// Write would turn the sentinel strings into numbers
+	if s, ok := v.(String); ok {
+		if e := NewEncoder(in).WriteString(s); e != nil {
+			t.Fatal(e)
+		}
+	} else if e := Write(in, v); e != nil {
+		t.Fatal(e)
+	}
+
+	tagged, e := ReadTagged(bytes.NewReader(in.Bytes()))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	js, e := json.Marshal(tagged)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	r, e := DecodeJSON(bytes.NewReader(js))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	out := &bytes.Buffer{}
+	if e := WriteTagged(out, r); e != nil {
+		t.Fatal(e)
+	}
+
+	if !bytes.Equal(in.Bytes(), out.Bytes()) {
+		t.Fatalf("round trip through %s differs:\n%x\n%x", js, in.Bytes(), out.Bytes())
+	}
+
+	return js
+}
+
+func TestTagged(t *testing.T) {
+	for v, expect := range map[interface{}]string{
+		float64(3):                               `3.0`,
+		int64(3):                                 `3`,
+		math.Copysign(0, -1):                     `-0.0`,
+		1e21:                                     `1e+21`,
+		math.NaN():                               `"____NaN____"`,
+		math.Float64frombits(0x7ff0000000000001): `"____NaN:7ff0000000000001____"`,
+		math.Inf(-1):                             `"____-Inf____"`,
+		String("____NaN____"):                    `"____=____NaN____"`,
+		String("____=x"):                         `"____=____=x"`,
+		String("__x"):                            `"__x"`,
+	} {
+		if js := taggedRoundTrip(t, v); string(js) != expect {
+			t.Fatalf("expect %s, got %s", expect, js)
+		}
+	}
+
+	r := rand.New(rand.NewSource(3))
+	for i := 0; i < 500; i++ {
+		taggedRoundTrip(t, randomValue(r, 4))
+	}
+
+	if e := WriteTagged(&bytes.Buffer{}, "____oops"); e == nil {
+		t.Fatal("expect an unescaped tag to fail")
+	}
+}
@@ -0,0 +1,236 @@
+package sbvj01
+
+import (
+	"encoding/json"
+	"math"
+
+	. "github.com/xhebox/sbutils/lib/data_types"
+)
+
+// Normalize turns json decoded by DecodeJSON into the types Read returns:
+// integers become int64, other numbers float64, and strings String, except
+// for the NaN/Inf strings, which become those numbers. Arrays and objects are
+// converted in place.
+func Normalize(v interface{}) interface{} {
+	switch n := v.(type) {
+	case json.Number:
+		if i, e := n.Int64(); e == nil {
+			return i
+		}
+
+		f, _ := n.Float64()
+		return f
+	case string:
+		switch n {
+		case "____NaN____":
+			return math.NaN()
+		case "____+Inf____":
+			return math.Inf(1)
+		case "____-Inf____":
+			return math.Inf(-1)
+		}
+		return String(n)
+	case []interface{}:
+		for k := range n {
+			n[k] = Normalize(n[k])
+		}
+	case Object:
+		for k := range n {
+			n[k].Value = Normalize(n[k].Value)
+		}
+	}
+
+	return v
+}
+
+// JSONValue returns v with NaN and Inf replaced by the strings ____NaN____,
+// ____+Inf____ and ____-Inf____, which json can hold and Normalize and Write
+// turn back into numbers. Arrays and objects are copied only if they change.
+func JSONValue(v interface{}) interface{} {
+	switch n := v.(type) {
+	case float64:
+		switch {
+		case math.IsNaN(n):
+			return "____NaN____"
+		case math.IsInf(n, 1):
+			return "____+Inf____"
+		case math.IsInf(n, -1):
+			return "____-Inf____"
+		}
+	case []interface{}:
+		var r []interface{}
+
+		for k := range n {
+			m := JSONValue(n[k])
+			if r == nil && !sameString(m, n[k]) {
+				r = make([]interface{}, len(n))
+				copy(r, n)
+			}
+
+			if r != nil {
+				r[k] = m
+			}
+		}
+
+		if r != nil {
+			return r
+		}
+	case Object:
+		var r Object
+
+		for k := range n {
+			m := JSONValue(n[k].Value)
+			if r == nil && !sameString(m, n[k].Value) {
+				r = make(Object, len(n))
+				copy(r, n)
+			}
+
+			if r != nil {
+				r[k].Value = m
+			}
+		}
+
+		if r != nil {
+			return r
+		}
+	}
+
+	return v
+}
+
+// Copy returns a deep copy of a value, as returned by Read or DecodeJSON.
+func Copy(v interface{}) interface{} {
+	switch n := v.(type) {
+	case []interface{}:
+		r := make([]interface{}, len(n))
+		for k := range n {
+			r[k] = Copy(n[k])
+		}
+		return r
+	case Object:
+		r := make(Object, len(n))
+		for k := range n {
+			r[k] = Pair{Key: n[k].Key, Value: Copy(n[k].Value)}
+		}
+		return r
+	case map[string]interface{}:
+		r := make(map[string]interface{}, len(n))
+		for k, v := range n {
+			r[k] = Copy(v)
+		}
+		return r
+	case map[String]interface{}:
+		r := make(map[String]interface{}, len(n))
+		for k, v := range n {
+			r[k] = Copy(v)
+		}
+		return r
+	}
+
+	return v
+}
+
+func asObject(v interface{}) (Object, bool) {
+	switch n := v.(type) {
+	case Object:
+		return n, true
+	case map[String]interface{}:
+		return sortedObject(n), true
+	case map[string]interface{}:
+		m := make(map[String]interface{}, len(n))
+		for k, v := range n {
+			m[String(k)] = v
+		}
+		return sortedObject(m), true
+	}
+
+	return nil, false
+}
+
+// Equal tells whether a and b are the same value to Write: a varint is never
+// equal to a float, floats are compared by their bits, strings by text, and
+// objects regardless of key order. Numbers and strings as DecodeJSON returns
+// them are normalized first.
+func Equal(a, b interface{}) bool {
+	return equal(a, b, true)
+}
+
+// Equivalent is Equal with numbers compared by value, as the game compares
+// json: a varint 1 equals a float 1.0, integers are compared exactly, and NaN
+// equals nothing.
+func Equivalent(a, b interface{}) bool {
+	return equal(a, b, false)
+}
+
+func equal(a, b interface{}, strict bool) bool {
+	switch a.(type) {
+	case json.Number, string:
+		a = Normalize(a)
+	}
+
+	switch b.(type) {
+	case json.Number, string:
+		b = Normalize(b)
+	}
+
+	if !strict {
+		if x, ok := number(a); ok {
+			y, ok := number(b)
+			if !ok {
+				return false
+			}
+
+			c, ok := compareNumber(x, y)
+			return ok && c == 0
+		}
+	}
+
+	switch x := a.(type) {
+	case nil:
+		return b == nil
+	case bool:
+		y, ok := b.(bool)
+		return ok && x == y
+	case int64:
+		y, ok := b.(int64)
+		return ok && x == y
+	case float64:
+		y, ok := b.(float64)
+		return ok && math.Float64bits(x) == math.Float64bits(y)
+	case String:
+		y, ok := b.(String)
+		return ok && x == y
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+
+		for k := range x {
+			if !equal(x[k], y[k], strict) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	x, ok := asObject(a)
+	if !ok {
+		return false
+	}
+
+	y, ok := asObject(b)
+	if !ok || len(x) != len(y) {
+		return false
+	}
+
+	for _, p := range x {
+		v, ok := y.Get(p.Key)
+		if !ok || !equal(p.Value, v, strict) {
+			return false
+		}
+	}
+
+	return true
+}
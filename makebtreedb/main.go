@@ -23,24 +23,65 @@ func Exists(name string) bool {
 	return !os.IsNotExist(err)
 }
 
+func get(o sbvj01.Object, key string) interface{} {
+	v, ok := o.Get(data_types.String(key))
+	if !ok {
+		log.Fatalf("missing %s\n", key)
+	}
+	return v
+}
+
+func number(v interface{}) int64 {
+	n, e := v.(json.Number).Int64()
+	if e != nil {
+		log.Fatalln(e)
+	}
+	return n
+}
+
+func readHdr(o sbvj01.Object) sbvj01.VerJsonHdr {
+	return sbvj01.VerJsonHdr{
+		Id:        data_types.String(get(o, "id").(string)),
+		Versioned: get(o, "versioned").(bool),
+		Version:   int32(uint32(number(get(o, "version")))),
+	}
+}
+
 func main() {
-	var in, dir string
-	var root bool
+	var in, dir, policy string
+	var root, relaxed bool
 	flag.StringVar(&in, "i", "input", "db file")
 	flag.StringVar(&dir, "d", "dir", "records dir")
 	flag.BoolVar(&root, "r", false, "root")
+	flag.BoolVar(&relaxed, "relaxed", false, "accept comments and trailing commas in json")
+	flag.StringVar(&policy, "utf8", "passthrough", "invalid utf-8 strings: passthrough/reject/replace")
 	flag.Parse()
 	log.SetFlags(log.Llongfile)
 
+	sp, e := data_types.ParseStringPolicy(policy)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	opts := sbvj01.EncodeOptions{Strings: sp}
+
+	// strings keep their invalid utf-8, for opts to apply when written
+	decode := sbvj01.DecodeRawJSON
+	if relaxed {
+		decode = sbvj01.DecodeRelaxedRawJSON
+	}
+
 	var h *btreedb5.BTreeDB5
-	var e error
 	if Exists(in) {
 		h, e = btreedb5.Load(in)
 		if e != nil {
 			log.Fatalln(e)
 		}
 	} else {
-		h, e = btreedb5.New(in, "World4", 2048, 5)
+		h, e = btreedb5.Create(in, btreedb5.Options{
+			Identifier: "World4",
+			BlockSize:  2048,
+			KeySize:    5,
+		})
 		if e != nil {
 			log.Fatalln(e)
 		}
@@ -75,47 +116,39 @@ func main() {
 
 		switch {
 		case fname == "metadata":
-			content := map[string]interface{}{}
-
-			e := json.Unmarshal(fc, &content)
+			r, e := decode(fc)
 			if e != nil {
-				log.Fatalln(e)
+				log.Fatalln(fname, e)
 			}
+			content := r.(sbvj01.Object)
 
-			size := content["size"].([]interface{})
+			size := get(content, "size").([]interface{})
 
-			e = byteorder.PutUint32(zw, byteorder.BigEndian, uint32(size[0].(float64)))
+			e = byteorder.PutUint32(zw, byteorder.BigEndian, uint32(number(size[0])))
 			if e != nil {
 				log.Fatalln(e)
 			}
 
-			e = byteorder.PutUint32(zw, byteorder.BigEndian, uint32(size[1].(float64)))
+			e = byteorder.PutUint32(zw, byteorder.BigEndian, uint32(number(size[1])))
 			if e != nil {
 				log.Fatalln(e)
 			}
 
-			hdr := content["hdr"].(map[string]interface{})
-
-			e = sbvj01.WriteHdr(zw, sbvj01.VerJsonHdr{
-				Id:        data_types.String(hdr["id"].(string)),
-				Versioned: hdr["versioned"].(bool),
-				Version:   int32(uint32(hdr["version"].(float64))),
-			})
+			e = opts.WriteHdr(zw, readHdr(get(content, "hdr").(sbvj01.Object)))
 			if e != nil {
-				log.Fatalln(e)
+				log.Fatalln(fname, e)
 			}
 
-			e = sbvj01.Write(zw, content["body"])
+			e = opts.Write(zw, get(content, "body"))
 			if e != nil {
-				log.Fatalln(e)
+				log.Fatalln(fname, e)
 			}
 		case strings.HasPrefix(fname, "type2_"):
-			content := []interface{}{}
-
-			e := json.Unmarshal(fc, &content)
+			r, e := decode(fc)
 			if e != nil {
-				log.Fatalln(e)
+				log.Fatalln(fname, e)
 			}
+			content := r.([]interface{})
 
 			e = byteorder.PutUVarint(zw, byteorder.BigEndian, uint64(uint(len(content))))
 			if e != nil {
@@ -123,19 +156,16 @@ func main() {
 			}
 
 			for k := range content {
-				ii := content[k].(map[string]interface{})
+				ii := content[k].(sbvj01.Object)
 
-				hdr := ii["hdr"].(map[string]interface{})
-
-				e = sbvj01.WriteHdr(zw, sbvj01.VerJsonHdr{
-					Id:        data_types.String(hdr["id"].(string)),
-					Versioned: hdr["versioned"].(bool),
-					Version:   int32(uint32(hdr["version"].(float64))),
-				})
+				e = opts.WriteHdr(zw, readHdr(get(ii, "hdr").(sbvj01.Object)))
+				if e != nil {
+					log.Fatalln(fname, e)
+				}
 
-				e = sbvj01.Write(zw, ii["body"])
+				e = opts.Write(zw, get(ii, "body"))
 				if e != nil {
-					log.Fatalln(e)
+					log.Fatalln(fname, e)
 				}
 			}
 		default:
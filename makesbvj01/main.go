@@ -1,26 +1,83 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
 	"flag"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 
-	"../lib/sbvj01"
-	"github.com/xhebox/bstruct/byteorder"
+	"github.com/pkg/errors"
+	"github.com/xhebox/sbutils/lib/data_types"
+	"github.com/xhebox/sbutils/lib/sbvj01"
 )
 
+// decode reads the documents of contents for the container c. Strings keep
+// their invalid utf-8, for the policy to apply when they are written.
+func decode(contents []byte, c sbvj01.Container, relaxed bool) ([]sbvj01.Document, error) {
+	parse := sbvj01.DecodeRawJSON
+	if relaxed {
+		parse = sbvj01.DecodeRelaxedRawJSON
+	}
+
+	r, e := parse(contents)
+	if e != nil {
+		return nil, e
+	}
+
+	switch c {
+	case sbvj01.Raw:
+		return []sbvj01.Document{{Body: r}}, nil
+	case sbvj01.NVJ:
+		v, ok := r.([]interface{})
+		if !ok {
+			return nil, errors.New("expect an array of documents")
+		}
+
+		docs := []sbvj01.Document{}
+		for k := range v {
+			doc, e := sbvj01.DecodeDocument(v[k])
+			if e != nil {
+				return nil, e
+			}
+
+			docs = append(docs, doc)
+		}
+
+		return docs, nil
+	}
+
+	doc, e := sbvj01.DecodeDocument(r)
+	if e != nil {
+		return nil, e
+	}
+
+	return []sbvj01.Document{doc}, nil
+}
+
 func main() {
-	var in, out, mode string
+	var in, out, mode, policy string
+	var tagged, relaxed bool
 	flag.StringVar(&in, "i", "input", "input json")
 	flag.StringVar(&out, "o", "stdout", "output versioned json")
-	flag.StringVar(&mode, "m", "vj", "vjmagic/vj/raw")
+	flag.StringVar(&mode, "m", "vj", "vjmagic/vj/raw/nvj")
+	flag.BoolVar(&tagged, "t", false, "tagged json, keeps number types and escapes strings")
+	flag.BoolVar(&relaxed, "relaxed", false, "accept comments and trailing commas in json")
+	flag.StringVar(&policy, "utf8", "passthrough", "invalid utf-8 strings: passthrough/reject/replace")
 	flag.Parse()
 	log.SetFlags(log.Llongfile)
 
+	c, e := sbvj01.ParseContainer(mode)
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	sp, e := data_types.ParseStringPolicy(policy)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	opts := sbvj01.EncodeOptions{Strings: sp}
+
 	contents, e := ioutil.ReadFile(in)
 	if e != nil {
 		log.Fatalln(e)
@@ -30,7 +87,7 @@ func main() {
 	if out == "stdout" {
 		outwt = os.Stdout
 	} else {
-		f, e := os.OpenFile(out, os.O_CREATE|os.O_RDWR, 0644)
+		f, e := os.OpenFile(out, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
 		if e != nil {
 			log.Fatalln(e)
 		}
@@ -39,69 +96,17 @@ func main() {
 		outwt = f
 	}
 
-	switch mode {
-	case "raw":
-		var r interface{}
-
-		d := json.NewDecoder(bytes.NewReader(contents))
-		d.UseNumber()
-
-		if e := d.Decode(&r); e != nil {
-			log.Fatalln(e)
-		}
-
-		if e := sbvj01.WriteRaw(outwt, r, byteorder.BigEndian); e != nil {
-			log.Fatalln(e)
-		}
-	case "vj":
-		var r interface{}
-
-		d := json.NewDecoder(bytes.NewReader(contents))
-		d.UseNumber()
-
-		if e := d.Decode(&r); e != nil {
-			log.Fatalln(e)
-		}
-
-		v, ok := r.(map[string]interface{})
-		if !ok {
-			log.Fatalln("not a versioned json?")
-		}
-
-		version, e := v["version"].(json.Number).Int64()
-		if e != nil {
-			log.Fatalln(e)
-		}
-
-		vj := sbvj01.VersionedJson{Id: v["id"].(string), Version: int(version), Content: v["content"]}
-
-		if e := sbvj01.Write(outwt, vj); e != nil {
-			log.Fatalln(e)
-		}
-	case "vjmagic":
-		var r interface{}
-
-		d := json.NewDecoder(bytes.NewReader(contents))
-		d.UseNumber()
-
-		if e := d.Decode(&r); e != nil {
-			log.Fatalln(e)
-		}
-
-		v, ok := r.(map[string]interface{})
-		if !ok {
-			log.Fatalln("not a versioned json?")
-		}
-
-		version, e := v["version"].(json.Number).Int64()
-		if e != nil {
-			log.Fatalln(e)
-		}
+	write := opts.WriteFile
+	if tagged {
+		write = opts.WriteFileTagged
+	}
 
-		vj := sbvj01.VersionedJson{Id: v["id"].(string), Version: int(version), Content: v["content"]}
+	docs, e := decode(contents, c, relaxed)
+	if e != nil {
+		log.Fatalln(in, e)
+	}
 
-		if e := sbvj01.WriteMagic(outwt, vj); e != nil {
-			log.Fatalln(e)
-		}
+	if e := write(outwt, c, docs...); e != nil {
+		log.Fatalf("%+v\n", e)
 	}
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/xhebox/sbutils/lib/data_types"
+	"github.com/xhebox/sbutils/lib/sbvj01"
+)
+
+func TestPolicy(t *testing.T) {
+	// the bad byte announces a 3 byte sequence, which must not eat the quote
+	contents := []byte("{\"hdr\": {\"id\": \"x\xe4\", \"versioned\": false, \"version\": 0}, \"body\": {\"a\": \"b\xe4\", \"c\": [\"d\xc3\"]}}")
+
+	write := func(p data_types.StringPolicy) ([]byte, error) {
+		docs, e := decode(contents, sbvj01.VJ, false)
+		if e != nil {
+			return nil, e
+		}
+
+		buf := &bytes.Buffer{}
+		e = sbvj01.EncodeOptions{Strings: p}.WriteFile(buf, sbvj01.VJ, docs...)
+		return buf.Bytes(), e
+	}
+
+	for p, want := range map[data_types.StringPolicy]sbvj01.Document{
+		data_types.Passthrough: {
+			Header: sbvj01.VerJsonHdr{Id: "x\xe4"},
+			Body:   sbvj01.Object{{Key: "a", Value: data_types.String("b\xe4")}, {Key: "c", Value: []interface{}{data_types.String("d\xc3")}}},
+		},
+		data_types.Replace: {
+			Header: sbvj01.VerJsonHdr{Id: "xI"},
+			Body:   sbvj01.Object{{Key: "a", Value: data_types.String("bI")}, {Key: "c", Value: []interface{}{data_types.String("dI")}}},
+		},
+	} {
+		b, e := write(p)
+		if e != nil {
+			t.Fatalf("%s: %+v", p, e)
+		}
+
+		docs, _, e := sbvj01.ReadFileBuf(b, sbvj01.VJ)
+		if e != nil {
+			t.Fatalf("%s: %+v", p, e)
+		}
+
+		if len(docs) != 1 || !sbvj01.Equal(docs[0].Body, want.Body) || docs[0].Header != want.Header {
+			t.Errorf("%s: got %+v", p, docs)
+		}
+	}
+
+	if _, e := write(data_types.Reject); e == nil {
+		t.Fatal("reject: no error")
+	} else if _, ok := errors.Cause(e).(*data_types.InvalidUTF8Error); !ok {
+		t.Fatalf("reject: %+v", e)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/xhebox/sbutils/lib/btreedb5"
+)
+
+func main() {
+	var in, out, ident string
+	var blksz, keysz int
+	var overwrite, partial bool
+	flag.StringVar(&in, "i", "input", "damaged db file")
+	flag.StringVar(&out, "o", "output", "new db file")
+	flag.StringVar(&ident, "id", "", "identifier, read from the header if empty")
+	flag.IntVar(&blksz, "b", 0, "block size, read from the header if 0")
+	flag.IntVar(&keysz, "k", 0, "key size, read from the header if 0")
+	flag.BoolVar(&overwrite, "f", false, "overwrite the new db file if it exists")
+	flag.BoolVar(&partial, "p", false, "also keep records from broken leaf chains")
+	flag.Parse()
+	log.SetFlags(log.Llongfile)
+
+	r, e := btreedb5.Salvage(in, out, btreedb5.SalvageOptions{
+		Identifier: ident,
+		BlockSize:  blksz,
+		KeySize:    keysz,
+		Overwrite:  overwrite,
+		Partial:    partial,
+	})
+	if e != nil {
+		log.Fatalf("%+v\n", e)
+	}
+
+	log.Printf("%+v\n", *r)
+}
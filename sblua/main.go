@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/xhebox/sbutils/lib/sbvj01"
+)
+
+func isJSON(contents []byte) bool {
+	s := bytes.TrimLeft(contents, " \t\r\n")
+	return len(s) != 0 && (s[0] == '{' || s[0] == '[')
+}
+
+func main() {
+	var in, out, mode, null string
+	var compact, sbmeta, relaxed bool
+	flag.StringVar(&in, "i", "input", "versioned json or json file")
+	flag.StringVar(&out, "o", "stdout", "output lua")
+	flag.StringVar(&mode, "m", "auto", "auto/json/vjmagic/vj/raw/nvj")
+	flag.BoolVar(&compact, "c", false, "output on one line")
+	flag.BoolVar(&sbmeta, "sbmeta", false, "wrap the table to get the game metatables, keeping \"1\" keys")
+	flag.BoolVar(&relaxed, "relaxed", false, "allow comments and trailing commas in json")
+	flag.StringVar(&null, "null", "", "lua written for a null inside an array, which is an error if empty")
+	flag.Parse()
+	log.SetFlags(log.Llongfile)
+
+	contents, e := ioutil.ReadFile(in)
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	if mode == "auto" && !bytes.HasPrefix(contents, sbvj01.Magic) && isJSON(contents) {
+		mode = "json"
+	}
+
+	var r interface{}
+	if mode == "json" {
+		if relaxed {
+			r, e = sbvj01.DecodeRelaxedJSON(bytes.NewReader(contents))
+		} else {
+			r, e = sbvj01.DecodeJSON(bytes.NewReader(contents))
+		}
+		if e != nil {
+			log.Fatalln(e)
+		}
+	} else {
+		c, e := sbvj01.ParseContainer(mode)
+		if e != nil {
+			log.Fatalln(e)
+		}
+
+		docs, c, e := sbvj01.ReadFileBuf(contents, c)
+		if e != nil {
+			log.Fatalln(e)
+		}
+
+		if c == sbvj01.NVJ {
+			bodies := make([]interface{}, len(docs))
+			for k := range docs {
+				bodies[k] = docs[k].Body
+			}
+			r = bodies
+		} else {
+			r = docs[0].Body
+		}
+	}
+
+	var outwt io.Writer
+	if out == "stdout" {
+		outwt = os.Stdout
+	} else {
+		f, e := os.OpenFile(out, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+		if e != nil {
+			log.Fatalln(e)
+		}
+		defer f.Close()
+
+		outwt = f
+	}
+
+	opts := sbvj01.LuaOptions{Indent: "\t", SbMeta: sbmeta, Null: null}
+	if compact {
+		opts.Indent = ""
+	}
+
+	if e := sbvj01.EncodeLua(outwt, r, opts); e != nil {
+		log.Fatalln(e)
+	}
+
+	if _, e := io.WriteString(outwt, "\n"); e != nil {
+		log.Fatalln(e)
+	}
+}
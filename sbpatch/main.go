@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/xhebox/sbutils/lib/atomicfile"
+	"github.com/xhebox/sbutils/lib/jsonpatch"
+	"github.com/xhebox/sbutils/lib/sbvj01"
+)
+
+func isJSON(contents []byte) bool {
+	s := bytes.TrimLeft(contents, " \t\r\n")
+	return len(s) != 0 && (s[0] == '{' || s[0] == '[')
+}
+
+func main() {
+	var in, out, mode string
+	flag.StringVar(&in, "i", "input", "versioned json or json file")
+	flag.StringVar(&out, "o", "", "output file, the input file if empty")
+	flag.StringVar(&mode, "m", "auto", "auto/json/vjmagic/vj/raw")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s: [flags] PATCH...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(log.Llongfile)
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	contents, e := ioutil.ReadFile(in)
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	if mode == "auto" && !bytes.HasPrefix(contents, sbvj01.Magic) && isJSON(contents) {
+		mode = "json"
+	}
+
+	var docs []sbvj01.Document
+	var c sbvj01.Container
+	var doc interface{}
+
+	if mode == "json" {
+		doc, e = sbvj01.DecodeJSON(bytes.NewReader(contents))
+		if e != nil {
+			log.Fatalln(e)
+		}
+	} else {
+		c, e = sbvj01.ParseContainer(mode)
+		if e != nil {
+			log.Fatalln(e)
+		}
+
+		if c == sbvj01.NVJ {
+			log.Fatalln("nvj files are not supported")
+		}
+
+		docs, c, e = sbvj01.ReadFileBuf(contents, c)
+		if e != nil {
+			log.Fatalln(e)
+		}
+
+		doc = docs[0].Body
+	}
+
+	for _, file := range flag.Args() {
+		f, e := os.Open(file)
+		if e != nil {
+			log.Fatalln(e)
+		}
+
+		p, e := jsonpatch.Read(f)
+		f.Close()
+		if e != nil {
+			log.Fatalf("%s: %+v\n", file, e)
+		}
+
+		if mode != "json" {
+			p.Normalize()
+		}
+
+		var r jsonpatch.Result
+		doc, r, e = p.Apply(doc)
+		if e != nil {
+			log.Fatalf("%s: %+v\n", file, e)
+		}
+
+		if r.Skipped != 0 {
+			log.Printf("%s: %d of %d batches skipped by tests\n", file, r.Skipped, len(p))
+		}
+	}
+
+	var res []byte
+	if mode == "json" {
+		res, e = json.MarshalIndent(doc, "", "\t")
+		if e != nil {
+			log.Fatalln(e)
+		}
+	} else {
+		docs[0].Body = doc
+
+		buf := &bytes.Buffer{}
+		if e := sbvj01.WriteFile(buf, c, docs...); e != nil {
+			log.Fatalln(e)
+		}
+		res = buf.Bytes()
+	}
+
+	if out == "" {
+		out = in
+	}
+
+	if e := atomicfile.WriteFile(out, res); e != nil {
+		log.Fatalln(e)
+	}
+}
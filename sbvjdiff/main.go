@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/xhebox/sbutils/lib/jsonpatch"
+	"github.com/xhebox/sbutils/lib/sbvj01"
+)
+
+func isJSON(contents []byte) bool {
+	s := bytes.TrimLeft(contents, " \t\r\n")
+	return len(s) != 0 && (s[0] == '{' || s[0] == '[')
+}
+
+func load(file, mode string) interface{} {
+	contents, e := ioutil.ReadFile(file)
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	if mode == "json" || (mode == "auto" && !bytes.HasPrefix(contents, sbvj01.Magic) && isJSON(contents)) {
+		r, e := sbvj01.DecodeJSON(bytes.NewReader(contents))
+		if e != nil {
+			log.Fatalf("%s: %+v\n", file, e)
+		}
+
+		return sbvj01.JSONValue(sbvj01.Normalize(r))
+	}
+
+	c, e := sbvj01.ParseContainer(mode)
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	if c == sbvj01.NVJ {
+		log.Fatalln("nvj files are not supported")
+	}
+
+	docs, _, e := sbvj01.ReadFileBuf(contents, c)
+	if e != nil {
+		log.Fatalf("%s: %+v\n", file, e)
+	}
+
+	return sbvj01.JSONValue(docs[0].Body)
+}
+
+func main() {
+	var out, mode, base string
+	flag.StringVar(&out, "o", "", "output file, stdout if empty")
+	flag.StringVar(&mode, "m", "auto", "auto/json/vjmagic/vj/raw")
+	flag.StringVar(&base, "base", "", "common base, for a three-way merge")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s: [flags] OLD NEW\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s: [flags] -base BASE OURS THEIRS\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(log.Llongfile)
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	a := load(flag.Arg(0), mode)
+	b := load(flag.Arg(1), mode)
+
+	conflicts := []jsonpatch.Conflict{}
+	if base != "" {
+		b, conflicts = jsonpatch.Merge3(load(base, mode), a, b)
+	}
+
+	res, e := json.MarshalIndent(jsonpatch.Diff(a, b), "", "\t")
+	if e != nil {
+		log.Fatalln(e)
+	}
+	res = append(res, '\n')
+
+	if out == "" {
+		os.Stdout.Write(res)
+	} else if e := ioutil.WriteFile(out, res, 0644); e != nil {
+		log.Fatalln(e)
+	}
+
+	for _, c := range conflicts {
+		path := c.Path
+		if path == "" {
+			path = "/"
+		}
+		fmt.Fprintf(os.Stderr, "conflict at %s\n", path)
+	}
+
+	if len(conflicts) != 0 {
+		os.Exit(1)
+	}
+}
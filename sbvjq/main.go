@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/xhebox/sbutils/lib/atomicfile"
+	"github.com/xhebox/sbutils/lib/sbvj01"
+)
+
+func main() {
+	var in, out, mode string
+	flag.StringVar(&in, "i", "input", "versioned json file")
+	flag.StringVar(&out, "o", "", "output file, the input file if empty")
+	flag.StringVar(&mode, "m", "auto", "auto/vjmagic/vj/raw")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s: [flags] get|delete PATH, or set|append PATH VALUE\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(log.Llongfile)
+
+	args := flag.Args()
+	if len(args) < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	op := args[0]
+
+	switch op {
+	case "get", "delete":
+		if len(args) != 2 {
+			log.Fatalf("%s takes a path\n", op)
+		}
+	case "set", "append":
+		if len(args) != 3 {
+			log.Fatalf("%s takes a path and a value\n", op)
+		}
+	default:
+		log.Fatalf("unknown operation %s\n", op)
+	}
+
+	path, e := sbvj01.ParsePath(args[1])
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	var value interface{}
+	if len(args) == 3 {
+		value, e = sbvj01.ParseLiteral(args[2])
+		if e != nil {
+			log.Fatalln(e)
+		}
+	}
+
+	c, e := sbvj01.ParseContainer(mode)
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	if c == sbvj01.NVJ {
+		log.Fatalln("nvj files are not supported")
+	}
+
+	contents, e := ioutil.ReadFile(in)
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	docs, c, e := sbvj01.ReadFileBuf(contents, c)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	doc := &docs[0]
+
+	if op == "get" {
+		for _, v := range path.Get(doc.Body) {
+			res, e := json.MarshalIndent(sbvj01.JSONValue(v), "", "\t")
+			if e != nil {
+				log.Fatalln(e)
+			}
+
+			fmt.Printf("%s\n", res)
+		}
+
+		return
+	}
+
+	var fn sbvj01.UpdateFunc
+	switch op {
+	case "set":
+		fn = func(v interface{}, exist bool) (interface{}, bool, error) {
+			return value, true, nil
+		}
+	case "delete":
+		fn = func(v interface{}, exist bool) (interface{}, bool, error) {
+			return v, false, nil
+		}
+	case "append":
+		fn = func(v interface{}, exist bool) (interface{}, bool, error) {
+			if !exist {
+				return v, false, nil
+			}
+
+			arr, ok := v.([]interface{})
+			if !ok {
+				return v, true, errors.Errorf("can not append to %T", v)
+			}
+
+			return append(arr, value), true, nil
+		}
+	}
+
+	body, n, e := path.Update(doc.Body, fn)
+	if e != nil {
+		log.Fatalln(e)
+	}
+
+	if n == 0 {
+		log.Fatalln("nothing matched")
+	}
+
+	doc.Body = body
+
+	buf := &bytes.Buffer{}
+	if e := sbvj01.WriteFile(buf, c, docs...); e != nil {
+		log.Fatalln(e)
+	}
+
+	if out == "" {
+		out = in
+	}
+
+	if e := atomicfile.WriteFile(out, buf.Bytes()); e != nil {
+		log.Fatalln(e)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/xhebox/sbutils/lib/btreedb5"
+	"github.com/xhebox/sbutils/lib/data_types"
+	"github.com/xhebox/sbutils/lib/sbvj01"
+)
+
+var found int
+
+func report(name string, docs []sbvj01.Document) {
+	for k := range docs {
+		prefix := name
+		if len(docs) > 1 {
+			prefix = fmt.Sprintf("%s[%d]", name, k)
+		}
+
+		if i := data_types.InvalidUTF8(string(docs[k].Header.Id)); i != -1 {
+			fmt.Printf("%s: header id: invalid utf-8 at byte %d\n", prefix, i)
+			found++
+		}
+
+		for _, s := range sbvj01.InvalidStrings(docs[k].Body) {
+			path := s.Path
+			if path == "" {
+				path = "/"
+			}
+
+			what := "string"
+			if s.Key {
+				what = "key"
+			}
+
+			fmt.Printf("%s: %s %s: invalid utf-8 at byte %d\n", prefix, what, path, s.Offset)
+			found++
+		}
+	}
+}
+
+func scanDB(file string) error {
+	h, e := btreedb5.Load(file)
+	if e != nil {
+		return e
+	}
+	defer h.Close()
+
+	return h.Ascend(func(key btreedb5.Key, data []byte) {
+		var c sbvj01.Container
+		var skip int
+		switch key[0] {
+		case 0:
+			c, skip = sbvj01.VJ, 8
+		case 2:
+			c = sbvj01.NVJ
+		default:
+			return
+		}
+
+		name := fmt.Sprintf("%s: record %s", file, hex.EncodeToString(key))
+
+		z, e := zlib.NewReader(bytes.NewReader(data))
+		if e != nil {
+			log.Printf("%s: %+v\n", name, errors.Wrapf(e, "fail to decompress"))
+			return
+		}
+		defer z.Close()
+
+		contents, e := ioutil.ReadAll(z)
+		if e != nil {
+			log.Printf("%s: %+v\n", name, e)
+			return
+		}
+
+		if len(contents) < skip {
+			log.Printf("%s: record too short\n", name)
+			return
+		}
+
+		docs, _, e := sbvj01.ReadFileBuf(contents[skip:], c)
+		if e != nil {
+			log.Printf("%s: %+v\n", name, e)
+			return
+		}
+
+		report(name, docs)
+	})
+}
+
+func main() {
+	var mode string
+	flag.StringVar(&mode, "m", "auto", "auto/vjmagic/vj/raw/nvj/btreedb")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s: [flags] FILE...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(log.Llongfile)
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	for _, file := range flag.Args() {
+		contents, e := ioutil.ReadFile(file)
+		if e != nil {
+			log.Fatalln(e)
+		}
+
+		if mode == "btreedb" || (mode == "auto" && bytes.HasPrefix(contents, btreedb5.Magic)) {
+			if e := scanDB(file); e != nil {
+				log.Fatalf("%s: %+v\n", file, e)
+			}
+			continue
+		}
+
+		c, e := sbvj01.ParseContainer(mode)
+		if e != nil {
+			log.Fatalln(e)
+		}
+
+		docs, _, e := sbvj01.ReadFileBuf(contents, c)
+		if e != nil {
+			log.Fatalf("%s: %+v\n", file, e)
+		}
+
+		report(file, docs)
+	}
+
+	if found != 0 {
+		os.Exit(1)
+	}
+}